@@ -0,0 +1,1270 @@
+package arco
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRows is a canned result set returned by a fakeHandler.
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
+
+// fakeHandler answers a query issued against a fake database.
+type fakeHandler func(query string, args []driver.Value) (*fakeRows, error)
+
+// fakeDriver is a database/sql driver which passes every query to the fakeHandler
+// registered under the data source name, so queries can be tested without Postgres.
+type fakeDriver struct {
+	mu       sync.Mutex
+	handlers map[string]fakeHandler
+}
+
+var testDriver = &fakeDriver{handlers: make(map[string]fakeHandler)}
+
+func init() {
+	sql.Register("arcotest", testDriver)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.handlers[name]
+	if !ok {
+		return nil, fmt.Errorf("arcotest: unknown database %q", name)
+	}
+	return fakeConn{h}, nil
+}
+
+type fakeConn struct {
+	handler fakeHandler
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{c.handler, query}, nil
+}
+
+func (c fakeConn) Close() error { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx passes the start of the transaction to the handler as a BEGIN statement,
+// for example "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY".
+func (c fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	query := "BEGIN"
+	if level := sql.IsolationLevel(opts.Isolation); level != sql.LevelDefault {
+		query += " ISOLATION LEVEL " + strings.ToUpper(level.String())
+	}
+	if opts.ReadOnly {
+		query += " READ ONLY"
+	}
+	if _, err := c.handler(query, nil); err != nil {
+		return nil, err
+	}
+	return fakeTx{c.handler}, nil
+}
+
+// fakeTx passes the end of a transaction to the handler as a COMMIT or ROLLBACK statement.
+type fakeTx struct {
+	handler fakeHandler
+}
+
+func (tx fakeTx) Commit() error {
+	_, err := tx.handler("COMMIT", nil)
+	return err
+}
+
+func (tx fakeTx) Rollback() error {
+	_, err := tx.handler("ROLLBACK", nil)
+	return err
+}
+
+type fakeStmt struct {
+	handler fakeHandler
+	query   string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("arcotest: exec not supported")
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	r, err := s.handler(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// newTestDB returns a DB whose queries are answered by h.
+func newTestDB(t *testing.T, h fakeHandler) DB {
+	testDriver.mu.Lock()
+	name := fmt.Sprintf("%s-%d", t.Name(), len(testDriver.handlers))
+	testDriver.handlers[name] = h
+	testDriver.mu.Unlock()
+
+	db, err := sql.Open("arcotest", name)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return OpenDB(db)
+}
+
+func TestPoolConfig(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	})
+
+	PoolConfig{}.apply(d.db)
+	if n := d.db.Stats().MaxOpenConnections; n != 0 {
+		t.Errorf("empty config: got %d max open connections, expected no limit", n)
+	}
+	PoolConfig{MaxOpenConns: 8, MaxIdleConns: 2, ConnMaxLifetime: time.Minute}.apply(d.db)
+	if n := d.db.Stats().MaxOpenConnections; n != 8 {
+		t.Errorf("got %d max open connections, expected 8", n)
+	}
+	d.SetMaxOpenConns(4)
+	if n := d.db.Stats().MaxOpenConnections; n != 4 {
+		t.Errorf("SetMaxOpenConns: got %d max open connections, expected 4", n)
+	}
+}
+
+func TestOpenDB(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		return &fakeRows{columns: []string{"v_version"}, values: [][]driver.Value{{"6.2u5"}}}, nil
+	})
+
+	shared := OpenDB(d.SQL())
+	if shared.SQL() != d.db {
+		t.Fatalf("OpenDB does not wrap the given handle")
+	}
+	if _, err := shared.SchemaVersion(context.Background()); err != nil {
+		t.Errorf("SchemaVersion on wrapped handle failed: %s", err)
+	}
+	if err := shared.Close(); err != nil {
+		t.Errorf("Close failed: %s", err)
+	}
+	if err := d.Ping(); err == nil {
+		t.Errorf("expected error using a handle closed through OpenDB")
+	}
+}
+
+func TestPing(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	})
+	if err := d.Ping(); err != nil {
+		t.Errorf("Ping failed: %s", err)
+	}
+
+	// The fake driver refuses to open a database with no registered handler
+	db, err := sql.Open("arcotest", "unreachable")
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	defer db.Close()
+	d = OpenDB(db)
+	if err := d.PingContext(context.Background()); err == nil {
+		t.Errorf("expected error pinging an unreachable database")
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	var queries []string
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		queries = append(queries, query)
+		return &fakeRows{columns: []string{"v_version"}, values: [][]driver.Value{{"6.2u5"}}}, nil
+	})
+
+	v, err := d.SchemaVersion(context.Background())
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %s", err)
+	}
+	if v != "6.2u5" {
+		t.Errorf("got version %q, expected %q", v, "6.2u5")
+	}
+	if len(queries) != 1 || queries[0] != schemaVersionQuery {
+		t.Errorf("unexpected queries: %v", queries)
+	}
+}
+
+func TestSchemaVersionMissing(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		return &fakeRows{columns: []string{"v_version"}}, nil
+	})
+
+	if _, err := d.SchemaVersion(context.Background()); err == nil {
+		t.Errorf("expected error for a database without a version row")
+	}
+}
+
+var accountingColumns = []string{"job_number", "task_number", "pe_taskid", "name", "group",
+	"username", "account", "project", "department", "submission_time", "ar_parent", "start_time", "end_time",
+	"wallclock_time", "cpu", "mem", "io", "iow", "maxvmem", "exit_status", "maxrss",
+	"host", "queue"}
+
+// accountingRow returns a view_accounting row for task t of job j with the given ar_parent.
+func accountingRow(j, t, ar int) []driver.Value {
+	submitted := time.Date(2012, 11, 1, 12, 0, 0, 0, time.UTC)
+	return []driver.Value{int64(j), int64(t), "NONE", "render", "users",
+		"bob", "sge", "some_project", "defaultdepartment", submitted, int64(ar), submitted.Add(time.Minute), submitted.Add(time.Hour),
+		int64(3540), 3000.5, 120.25, 1.5, 0.5, 1073741824.0, int64(0), int64(524288),
+		"node01", "all.q"}
+}
+
+// reservedWords are Postgres reserved words which must be quoted when used as column names.
+var reservedWords = map[string]bool{"group": true, "user": true}
+
+// selectColumns returns the names of the columns in the SELECT list of query, or an error if the list
+// would not be accepted by Postgres. The fake driver does not parse SQL, so this stands in for the server.
+func selectColumns(query string) ([]string, error) {
+	if !strings.HasPrefix(query, "SELECT ") {
+		return nil, fmt.Errorf("not a SELECT statement: %q", query)
+	}
+	from := strings.Index(query, "\nFROM ")
+	if from < 0 {
+		return nil, fmt.Errorf("no FROM clause: %q", query)
+	}
+	list := strings.TrimPrefix(query[:from], "SELECT ")
+	list = strings.TrimPrefix(list, "DISTINCT ")
+	var columns []string
+	for _, c := range strings.Split(list, ",") {
+		c = strings.TrimSpace(c)
+		if len(c) > 1 && c[0] == '"' && c[len(c)-1] == '"' {
+			c = c[1 : len(c)-1]
+		} else if reservedWords[c] {
+			return nil, fmt.Errorf("unquoted reserved word %s in %q", c, list)
+		}
+		if c == "" || strings.ContainsAny(c, " \t\n\\\"") {
+			return nil, fmt.Errorf("malformed column %q in %q", c, list)
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+func TestAccountingQueries(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		columns, err := selectColumns(query)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(columns, accountingColumns) {
+			return nil, fmt.Errorf("got columns %q, expected %q", columns, accountingColumns)
+		}
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{accountingRow(100, 1, 0)}}, nil
+	})
+
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	if _, err := d.QueryAccounting(100); err != nil {
+		t.Errorf("QueryAccounting failed: %s", err)
+	}
+	if _, err := d.QueryAccountingTask(100, 1); err != nil {
+		t.Errorf("QueryAccountingTask failed: %s", err)
+	}
+	if _, err := d.QueryAccountingTimes(start, end); err != nil {
+		t.Errorf("QueryAccountingTimes failed: %s", err)
+	}
+	if _, err := d.QueryAccountingByReservation(0, start, end); err != nil {
+		t.Errorf("QueryAccountingByReservation failed: %s", err)
+	}
+	if _, err := d.QueryAccountingByUser("bob", start, end); err != nil {
+		t.Errorf("QueryAccountingByUser failed: %s", err)
+	}
+	if _, err := d.QueryAccountingByProject("", start, end); err != nil {
+		t.Errorf("QueryAccountingByProject failed: %s", err)
+	}
+	if _, err := d.QueryAccountingByDepartment("", start, end); err != nil {
+		t.Errorf("QueryAccountingByDepartment failed: %s", err)
+	}
+	if _, err := d.QueryFailedJobs(start, end); err != nil {
+		t.Errorf("QueryFailedJobs failed: %s", err)
+	}
+	if _, err := d.QueryAccountingJobs([]int{100}); err != nil {
+		t.Errorf("QueryAccountingJobs failed: %s", err)
+	}
+	if _, err := d.QueryAccountingByHost("node01", start, end); err != nil {
+		t.Errorf("QueryAccountingByHost failed: %s", err)
+	}
+	if _, err := d.QueryAccountingByQueue("all.q", start, end); err != nil {
+		t.Errorf("QueryAccountingByQueue failed: %s", err)
+	}
+}
+
+func TestQueryAccountingByReservation(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != accountingReservationQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		gotArgs = args
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{
+			accountingRow(100, 1, 7),
+			accountingRow(101, 1, 7),
+		}}, nil
+	})
+
+	as, err := d.QueryAccountingByReservation(7, start, end)
+	if err != nil {
+		t.Fatalf("QueryAccountingByReservation failed: %s", err)
+	}
+	if expected := []driver.Value{int64(7), start, end}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("got args %v, expected %v", gotArgs, expected)
+	}
+	if len(as) != 2 {
+		t.Fatalf("got %d records, expected 2", len(as))
+	}
+	for i, a := range as {
+		if a.ARParent != 7 {
+			t.Errorf("%d: got ar_parent %d, expected 7", i, a.ARParent)
+		}
+	}
+	if as[0].JobNumber != 100 || as[1].JobNumber != 101 {
+		t.Errorf("got job numbers %d, %d, expected 100, 101", as[0].JobNumber, as[1].JobNumber)
+	}
+}
+
+func TestQueryAccountingByUser(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != accountingUserQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		gotArgs = args
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{
+			accountingRow(100, 1, 0),
+			accountingRow(100, 2, 0),
+		}}, nil
+	})
+
+	as, err := d.QueryAccountingByUser("bob", start, end)
+	if err != nil {
+		t.Fatalf("QueryAccountingByUser failed: %s", err)
+	}
+	if expected := []driver.Value{"bob", start, end}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("got args %v, expected %v", gotArgs, expected)
+	}
+	if len(as) != 2 || as[0].Username != "bob" || as[1].TaskNumber != 2 {
+		t.Errorf("unexpected records: %+v", as)
+	}
+}
+
+func TestQueryAccountingByProjectAndDepartment(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	var gotQuery string
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		gotQuery, gotArgs = query, args
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{accountingRow(100, 1, 0)}}, nil
+	})
+
+	tests := []struct {
+		name   string
+		query  func(string, time.Time, time.Time) ([]Accounting, error)
+		sql    string
+		column string
+		key    string
+	}{
+		{"project", d.QueryAccountingByProject, accountingProjectQuery, "project", "some_project"},
+		{"all projects", d.QueryAccountingByProject, accountingProjectQuery, "project", ""},
+		{"department", d.QueryAccountingByDepartment, accountingDepartmentQuery, "department", "defaultdepartment"},
+		{"all departments", d.QueryAccountingByDepartment, accountingDepartmentQuery, "department", ""},
+	}
+	for _, test := range tests {
+		as, err := test.query(test.key, start, end)
+		if err != nil {
+			t.Errorf("%s: query failed: %s", test.name, err)
+			continue
+		}
+		if len(as) != 1 {
+			t.Errorf("%s: got %d records, expected 1", test.name, len(as))
+		}
+		if gotQuery != test.sql {
+			t.Errorf("%s: unexpected query: %s", test.name, gotQuery)
+		}
+		if where := "WHERE ($1 = '' OR " + test.column + " = $1) AND start_time < $3 AND end_time > $2"; !strings.Contains(gotQuery, where) {
+			t.Errorf("%s: query does not filter with %q", test.name, where)
+		}
+		if expected := []driver.Value{test.key, start, end}; !reflect.DeepEqual(gotArgs, expected) {
+			t.Errorf("%s: got args %v, expected %v", test.name, gotArgs, expected)
+		}
+	}
+}
+
+func TestQueryFailedJobs(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != accountingFailedQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		gotArgs = args
+		failed := accountingRow(100, 1, 0)
+		failed[19] = int64(137)
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{failed}}, nil
+	})
+
+	as, err := d.QueryFailedJobs(start, end)
+	if err != nil {
+		t.Fatalf("QueryFailedJobs failed: %s", err)
+	}
+	if expected := []driver.Value{start, end}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("got args %v, expected %v", gotArgs, expected)
+	}
+	if len(as) != 1 || as[0].ExitStatus != 137 || !as[0].Failed() {
+		t.Errorf("unexpected records: %+v", as)
+	}
+	if (Accounting{}).Failed() {
+		t.Errorf("exit status 0 reported as failed")
+	}
+}
+
+func TestQueryAccountingJobs(t *testing.T) {
+	var queries int
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != accountingJobsQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		queries++
+		gotArgs = args
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{
+			accountingRow(100, 1, 0),
+			accountingRow(100, 2, 0),
+			accountingRow(102, 1, 0),
+		}}, nil
+	})
+
+	as, err := d.QueryAccountingJobs([]int{102, 100})
+	if err != nil {
+		t.Fatalf("QueryAccountingJobs failed: %s", err)
+	}
+	if expected := []driver.Value{"{102,100}"}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("got args %v, expected %v", gotArgs, expected)
+	}
+	if len(as) != 3 || as[0].JobNumber != 100 || as[2].JobNumber != 102 {
+		t.Errorf("unexpected records: %+v", as)
+	}
+
+	if as, err := d.QueryAccountingJobs(nil); as != nil || err != nil || queries != 1 {
+		t.Errorf("no jobs: got %v, %v after %d queries, expected no query", as, err, queries)
+	}
+}
+
+func TestQueryAccountingByHostAndQueue(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+
+	var gotQuery string
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		gotQuery, gotArgs = query, args
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{accountingRow(100, 1, 0)}}, nil
+	})
+
+	tests := []struct {
+		name   string
+		query  func(string, time.Time, time.Time) ([]Accounting, error)
+		sql    string
+		column string
+		key    string
+	}{
+		{"host", d.QueryAccountingByHost, accountingHostQuery, "host", "node01"},
+		{"queue", d.QueryAccountingByQueue, accountingQueueQuery, "queue", "all.q"},
+	}
+	for _, test := range tests {
+		as, err := test.query(test.key, start, end)
+		if err != nil {
+			t.Errorf("%s: query failed: %s", test.name, err)
+			continue
+		}
+		if len(as) != 1 || as[0].Host != "node01" || as[0].Queue != "all.q" {
+			t.Errorf("%s: unexpected records: %+v", test.name, as)
+		}
+		if gotQuery != test.sql || !strings.Contains(gotQuery, "WHERE "+test.column+" = $1 AND") {
+			t.Errorf("%s: unexpected query: %s", test.name, gotQuery)
+		}
+		if expected := []driver.Value{test.key, start, end}; !reflect.DeepEqual(gotArgs, expected) {
+			t.Errorf("%s: got args %v, expected %v", test.name, gotArgs, expected)
+		}
+	}
+}
+
+func TestJobDependencies(t *testing.T) {
+	// 10 <- 11 <- 12, and 13 waits for both 11 and a job named "prep"
+	holds := map[int64]string{11: "10", 12: "11", 13: "11,prep"}
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		switch query {
+		case predecessorsQuery:
+			r := &fakeRows{columns: []string{"jr_value"}}
+			if v, ok := holds[args[0].(int64)]; ok {
+				r.values = append(r.values, []driver.Value{v})
+			}
+			return r, nil
+		case successorsQuery:
+			r := &fakeRows{columns: []string{"j_job_number"}}
+			for _, j := range []int64{11, 12, 13} {
+				for _, h := range strings.Split(holds[j], ",") {
+					if h == args[0].(string) {
+						r.values = append(r.values, []driver.Value{j})
+					}
+				}
+			}
+			return r, nil
+		}
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	})
+
+	tests := []struct {
+		job          int
+		predecessors []int
+		successors   []int
+	}{
+		{10, nil, []int{11}},
+		{11, []int{10}, []int{12, 13}},
+		{12, []int{11}, nil},
+		{13, []int{11}, nil},
+	}
+	for _, test := range tests {
+		p, s, err := d.JobDependencies(test.job)
+		if err != nil {
+			t.Errorf("%d: JobDependencies failed: %s", test.job, err)
+			continue
+		}
+		if !reflect.DeepEqual(p, test.predecessors) || !reflect.DeepEqual(s, test.successors) {
+			t.Errorf("%d: got %v, %v, expected %v, %v", test.job, p, s, test.predecessors, test.successors)
+		}
+	}
+}
+
+func TestMissingArrayTasks(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != accountedTasksQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		if args[0].(int64) != 100 {
+			return &fakeRows{columns: []string{"task_number"}}, nil
+		}
+		return &fakeRows{
+			columns: []string{"task_number"},
+			values:  [][]driver.Value{{int64(1)}, {int64(2)}, {int64(4)}, {int64(7)}},
+		}, nil
+	})
+
+	tests := []struct {
+		job      int
+		size     int
+		step     int
+		expected []int
+	}{
+		{100, 8, 1, []int{3, 5, 6, 8}},
+		{100, 4, 1, []int{3}},
+		{100, 2, 1, nil},
+		{101, 3, 1, []int{1, 2, 3}},
+		{100, 8, 3, nil},
+		{100, 9, 2, []int{3, 5, 9}},
+	}
+	for _, test := range tests {
+		missing, err := d.MissingArrayTasks(test.job, test.size, test.step)
+		if err != nil {
+			t.Errorf("%d/%d:%d: MissingArrayTasks failed: %s", test.job, test.size, test.step, err)
+			continue
+		}
+		if !reflect.DeepEqual(missing, test.expected) {
+			t.Errorf("%d/%d:%d: got %v, expected %v", test.job, test.size, test.step, missing, test.expected)
+		}
+	}
+	if _, err := d.MissingArrayTasks(100, 8, 0); err == nil {
+		t.Errorf("expected an error for a step of 0")
+	}
+}
+
+func TestQueryAccountingContext(t *testing.T) {
+	var queries []string
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		queries = append(queries, query)
+		return &fakeRows{columns: accountingColumns, values: [][]driver.Value{accountingRow(100, 1, 0)}}, nil
+	})
+
+	as, err := d.QueryAccountingContext(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("QueryAccountingContext failed: %s", err)
+	}
+	if len(as) != 1 || as[0].JobNumber != 100 {
+		t.Errorf("unexpected records: %+v", as)
+	}
+	if len(queries) != 1 || queries[0] != accountingQuery {
+		t.Errorf("unexpected queries: %v", queries)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	queries = nil
+	if _, err := d.QueryAccountingContext(ctx, 100); !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryAccountingContext: got error %v, expected context.Canceled", err)
+	}
+	if _, err := d.QueryJobContext(ctx, 100); !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryJobContext: got error %v, expected context.Canceled", err)
+	}
+	if _, err := d.QueryLogsContext(ctx, 100, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryLogsContext: got error %v, expected context.Canceled", err)
+	}
+	if len(queries) != 0 {
+		t.Errorf("queries were run with a cancelled context: %v", queries)
+	}
+}
+
+var logColumns = []string{"job_number", "task_number", "pe_taskid", "name", "user", "account", "project",
+	"department", "time", "event", "state", "initiator", "host", "message"}
+
+func TestQueryLogs(t *testing.T) {
+	logged := time.Date(2012, 11, 1, 12, 0, 0, 0, time.UTC)
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		columns, err := selectColumns(query)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(columns, logColumns) {
+			return nil, fmt.Errorf("got columns %q, expected %q", columns, logColumns)
+		}
+		gotArgs = args
+		return &fakeRows{columns: logColumns, values: [][]driver.Value{
+			{int64(100), int64(1), "NONE", "render", "bob", "sge", "some_project", "defaultdepartment",
+				logged, "pending", "pending", "bob", "", "new job"},
+			{int64(100), int64(1), "2", "render", "bob", "sge", "some_project", "defaultdepartment",
+				logged.Add(time.Minute), "started", "running", "sge", "node01", "job started"},
+		}}, nil
+	})
+
+	logs, err := d.QueryLogs(100, 1)
+	if err != nil {
+		t.Fatalf("QueryLogs failed: %s", err)
+	}
+	if expected := []driver.Value{int64(100), int64(1)}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("got args %v, expected %v", gotArgs, expected)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("got %d log entries, expected 2", len(logs))
+	}
+	if l := logs[0]; l.User != "bob" || l.PETaskId != 0 || l.Event != "pending" || !l.Time.Equal(logged) {
+		t.Errorf("unexpected first entry: %+v", l)
+	}
+	if l := logs[1]; l.PETaskId != 2 || l.Host != "node01" || l.Message != "job started" {
+		t.Errorf("unexpected second entry: %+v", l)
+	}
+}
+
+func TestQueryAccountingPage(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	// Five records for user bob, paged by the fake database
+	var gotQuery string
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		gotQuery, gotArgs = query, args
+		var all [][]driver.Value
+		for i := 0; i < 5; i++ {
+			all = append(all, accountingRow(100+i, 1, 0))
+		}
+		offset, limit, rest := 0, len(all), args[3:]
+		if strings.Contains(query, "\nLIMIT ") {
+			limit, rest = int(rest[0].(int64)), rest[1:]
+		}
+		if strings.Contains(query, "\nOFFSET ") {
+			offset = int(rest[0].(int64))
+		}
+		all = all[min(offset, len(all)):]
+		return &fakeRows{columns: accountingColumns, values: all[:min(limit, len(all))]}, nil
+	})
+
+	tests := []struct {
+		page   PageOptions
+		suffix string
+		jobs   []int
+		more   bool
+	}{
+		{PageOptions{}, "ORDER BY submission_time, job_number, task_number, pe_taskid", []int{100, 101, 102, 103, 104}, false},
+		{PageOptions{Limit: 2}, "\nLIMIT $4", []int{100, 101}, true},
+		{PageOptions{Limit: 2, Offset: 2}, "\nLIMIT $4\nOFFSET $5", []int{102, 103}, true},
+		{PageOptions{Limit: 2, Offset: 4}, "\nLIMIT $4\nOFFSET $5", []int{104}, false},
+		{PageOptions{Offset: 3}, "\nOFFSET $4", []int{103, 104}, false},
+	}
+	for _, test := range tests {
+		p, err := d.QueryAccountingByUserPage("bob", start, end, test.page)
+		if err != nil {
+			t.Errorf("%+v: QueryAccountingByUserPage failed: %s", test.page, err)
+			continue
+		}
+		if !strings.HasSuffix(gotQuery, test.suffix) {
+			t.Errorf("%+v: query %q does not end with %q", test.page, gotQuery, test.suffix)
+		}
+		if test.page.Limit > 0 && gotArgs[3] != int64(test.page.Limit+1) {
+			t.Errorf("%+v: got limit %v, expected %d", test.page, gotArgs[3], test.page.Limit+1)
+		}
+		var jobs []int
+		for _, a := range p.Records {
+			jobs = append(jobs, a.JobNumber)
+		}
+		if !reflect.DeepEqual(jobs, test.jobs) || p.More != test.more {
+			t.Errorf("%+v: got jobs %v, more %t, expected %v, %t", test.page, jobs, p.More, test.jobs, test.more)
+		}
+	}
+}
+
+func TestQueryAccountingStream(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != accountingQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		var values [][]driver.Value
+		for i := 1; i <= 3; i++ {
+			values = append(values, accountingRow(100, i, 0))
+		}
+		return &fakeRows{columns: accountingColumns, values: values}, nil
+	})
+
+	records, errc := d.QueryAccountingStream(context.Background(), 100)
+	var tasks []int
+	for a := range records {
+		tasks = append(tasks, a.TaskNumber)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("QueryAccountingStream failed: %s", err)
+	}
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("got tasks %v, expected %v", tasks, expected)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	records, errc = d.QueryAccountingStream(ctx, 100)
+	if _, ok := <-records; !ok {
+		t.Fatalf("got no records before cancelling")
+	}
+	cancel()
+	for range records {
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, expected context.Canceled", err)
+	}
+	// Both channels are closed and the connection released
+	if _, ok := <-errc; ok {
+		t.Errorf("error channel was not closed")
+	}
+	if n := d.db.Stats().InUse; n != 0 {
+		t.Errorf("%d connections still in use", n)
+	}
+}
+
+func TestQueryAccountingStreamError(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		return nil, fmt.Errorf("relation \"view_accounting\" does not exist")
+	})
+
+	records, errc := d.QueryAccountingTimesStream(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	if _, ok := <-records; ok {
+		t.Errorf("got a record from a failed query")
+	}
+	if err := <-errc; err == nil || !strings.Contains(err.Error(), "view_accounting") {
+		t.Errorf("got error %v, expected the query error", err)
+	}
+}
+
+var jobColumns = []string{"j_job_number", "j_task_number", "j_pe_taskid", "j_job_name", "j_group", "j_owner",
+	"j_account", "j_priority", "j_submission_time", "j_project", "j_department"}
+
+func TestQueryJob(t *testing.T) {
+	submitted := time.Date(2012, 11, 1, 12, 0, 0, 0, time.UTC)
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != jobQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		r := &fakeRows{columns: jobColumns}
+		if args[0].(int64) == 100 {
+			r.values = [][]driver.Value{{int64(100), int64(-1), "NONE", "render", "users", "bob",
+				"sge", "0", submitted, "some_project", "defaultdepartment"}}
+		}
+		return r, nil
+	})
+
+	j, err := d.QueryJob(100)
+	if err != nil {
+		t.Fatalf("QueryJob failed: %s", err)
+	}
+	if j.JobNumber != 100 || j.Owner != "bob" || !j.SubmissionTime.Equal(submitted) {
+		t.Errorf("unexpected job: %+v", j)
+	}
+
+	j, err = d.QueryJob(101)
+	if !errors.Is(err, ErrJobNotFound) || j != nil {
+		t.Errorf("got %+v, %v, expected ErrJobNotFound", j, err)
+	}
+	if err != nil && err.Error() != "arco: job not found: 101" {
+		t.Errorf("got error string %q", err)
+	}
+}
+
+func TestQueryUsageSummary(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	var gotQuery string
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		gotQuery, gotArgs = query, args
+		return &fakeRows{
+			columns: []string{"key", "count", "cpu", "mem", "io", "wallclock_time"},
+			values: [][]driver.Value{
+				{"a", int64(3), 9000.5, 360.75, 4.5, int64(10620)},
+				{"b", int64(1), 60.0, 2.0, 0.0, int64(61)},
+			},
+		}, nil
+	})
+
+	tests := []struct {
+		groupBy string
+		column  string
+	}{
+		{GroupByUser, "username"},
+		{GroupByProject, "project"},
+		{GroupByDepartment, "department"},
+	}
+	for _, test := range tests {
+		summaries, err := d.QueryUsageSummary(start, end, test.groupBy)
+		if err != nil {
+			t.Errorf("%s: QueryUsageSummary failed: %s", test.groupBy, err)
+			continue
+		}
+		if !strings.Contains(gotQuery, "GROUP BY "+test.column+"\n") || !strings.HasPrefix(gotQuery, "SELECT COALESCE("+test.column+", '')") {
+			t.Errorf("%s: query not grouped by %s: %s", test.groupBy, test.column, gotQuery)
+		}
+		if expected := []driver.Value{start, end}; !reflect.DeepEqual(gotArgs, expected) {
+			t.Errorf("%s: got args %v, expected %v", test.groupBy, gotArgs, expected)
+		}
+		expected := []UsageSummary{
+			{GroupBy: test.groupBy, Key: "a", Jobs: 3, CPU: 9000.5, Memory: 360.75, IO: 4.5, WallClock: 10620},
+			{GroupBy: test.groupBy, Key: "b", Jobs: 1, CPU: 60, Memory: 2, WallClock: 61},
+		}
+		if !reflect.DeepEqual(summaries, expected) {
+			t.Errorf("%s: got %+v, expected %+v", test.groupBy, summaries, expected)
+		}
+	}
+
+	gotQuery = ""
+	if _, err := d.QueryUsageSummary(start, end, "username; DROP TABLE sge_job"); err == nil || gotQuery != "" {
+		t.Errorf("got error %v and query %q for an unknown dimension", err, gotQuery)
+	}
+}
+
+func TestAccountingDurations(t *testing.T) {
+	start := time.Date(2012, 11, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		a          Accounting
+		duration   time.Duration
+		efficiency float64
+	}{
+		{Accounting{StartTime: start, EndTime: start.Add(time.Hour), WallClockTime: 3600, CPU: 1800}, time.Hour, 0.5},
+		{Accounting{StartTime: start, EndTime: start.Add(time.Hour), WallClockTime: 3600, CPU: 14400}, time.Hour, 4},
+		{Accounting{StartTime: start, WallClockTime: 0, CPU: 10}, 0, 0},
+		{Accounting{EndTime: start}, 0, 0},
+		{Accounting{StartTime: start, EndTime: start.Add(-time.Second)}, 0, 0},
+	}
+	for i, test := range tests {
+		if d := test.a.Duration(); d != test.duration {
+			t.Errorf("%d: got duration %s, expected %s", i, d, test.duration)
+		}
+		if e := test.a.CPUEfficiency(); e != test.efficiency {
+			t.Errorf("%d: got efficiency %g, expected %g", i, e, test.efficiency)
+		}
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	var queries []string
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		queries = append(queries, query)
+		switch query {
+		case schemaVersionQuery:
+			return &fakeRows{columns: []string{"v_version"}, values: [][]driver.Value{{"6.2u5"}}}, nil
+		case accountingQuery:
+			return &fakeRows{columns: accountingColumns, values: [][]driver.Value{accountingRow(100, 1, 0)}}, nil
+		}
+		return &fakeRows{}, nil
+	})
+	begin := "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"
+
+	err := d.WithTx(context.Background(), func(tx *Tx) error {
+		if _, err := tx.SchemaVersion(context.Background()); err != nil {
+			return err
+		}
+		as, err := tx.QueryAccounting(100)
+		if len(as) != 1 {
+			t.Errorf("got %d records in transaction, expected 1", len(as))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %s", err)
+	}
+	if expected := []string{begin, schemaVersionQuery, accountingQuery, "COMMIT"}; !reflect.DeepEqual(queries, expected) {
+		t.Errorf("got statements %q, expected %q", queries, expected)
+	}
+
+	queries = nil
+	fnErr := errors.New("report failed")
+	if err := d.WithTx(context.Background(), func(tx *Tx) error { return fnErr }); err != fnErr {
+		t.Errorf("got error %v, expected %v", err, fnErr)
+	}
+	if expected := []string{begin, "ROLLBACK"}; !reflect.DeepEqual(queries, expected) {
+		t.Errorf("error: got statements %q, expected %q", queries, expected)
+	}
+
+	queries = nil
+	func() {
+		defer func() {
+			if p := recover(); p != "oops" {
+				t.Errorf("got panic %v, expected oops", p)
+			}
+		}()
+		d.WithTx(context.Background(), func(tx *Tx) error { panic("oops") })
+	}()
+	if expected := []string{begin, "ROLLBACK"}; !reflect.DeepEqual(queries, expected) {
+		t.Errorf("panic: got statements %q, expected %q", queries, expected)
+	}
+}
+
+var reservationColumns = []string{"ar_number", "ar_owner", "ar_submission_time", "ara_name", "ara_account",
+	"ara_start_time", "ara_end_time", "ara_granted_pe"}
+
+func TestQueryAdvanceReservations(t *testing.T) {
+	submitted := time.Date(2012, 11, 1, 9, 0, 0, 0, time.UTC)
+	reservation := func(n int64, pe string) []driver.Value {
+		start := submitted.Add(time.Duration(n) * time.Hour)
+		return []driver.Value{n, "bob", submitted, "maintenance", "sge", start, start.Add(2 * time.Hour), pe}
+	}
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		switch query {
+		case reservationQuery:
+			r := &fakeRows{columns: reservationColumns}
+			if args[0].(int64) == 7 {
+				r.values = [][]driver.Value{reservation(7, "mpi")}
+			}
+			return r, nil
+		case reservationsQuery:
+			return &fakeRows{columns: reservationColumns, values: [][]driver.Value{
+				reservation(7, "mpi"),
+				reservation(8, ""),
+			}}, nil
+		case reservationResourcesQuery:
+			r := &fakeRows{columns: []string{"arru_variable", "arru_value"}}
+			if args[0].(int64) == 7 {
+				r.values = [][]driver.Value{{"slots", "16"}, {"h_vmem", "4G"}}
+			}
+			return r, nil
+		}
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	})
+
+	ar, err := d.QueryAdvanceReservation(7)
+	if err != nil {
+		t.Fatalf("QueryAdvanceReservation failed: %s", err)
+	}
+	expected := AdvanceReservation{
+		ARNumber:       7,
+		Owner:          "bob",
+		SubmissionTime: submitted,
+		Name:           "maintenance",
+		Account:        "sge",
+		StartTime:      submitted.Add(7 * time.Hour),
+		EndTime:        submitted.Add(9 * time.Hour),
+		GrantedPE:      "mpi",
+		Resources:      map[string]string{"slots": "16", "h_vmem": "4G"},
+	}
+	if !reflect.DeepEqual(*ar, expected) {
+		t.Errorf("got %+v, expected %+v", *ar, expected)
+	}
+
+	if ar, err := d.QueryAdvanceReservation(9); !errors.Is(err, ErrReservationNotFound) || ar != nil {
+		t.Errorf("got %+v, %v, expected ErrReservationNotFound", ar, err)
+	}
+
+	ars, err := d.QueryAdvanceReservations(submitted, submitted.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("QueryAdvanceReservations failed: %s", err)
+	}
+	if len(ars) != 2 || ars[0].ARNumber != 7 || len(ars[0].Resources) != 2 || ars[1].ARNumber != 8 || len(ars[1].Resources) != 0 {
+		t.Errorf("unexpected reservations: %+v", ars)
+	}
+}
+
+func TestQueryShareUsage(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	logged := start.Add(time.Hour)
+
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != shareUsageQuery {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		gotArgs = args
+		return &fakeRows{
+			columns: []string{"sl_curr_time", "sl_node", "sl_user", "sl_project", "sl_shares", "sl_job_count",
+				"sl_actual_share", "sl_usage", "sl_cpu", "sl_mem", "sl_io", "sl_ltcpu", "sl_ltmem", "sl_ltio"},
+			values: [][]driver.Value{
+				{logged, "/Root", "", "", int64(1), int64(3), 1.0, 5400.0, 5000.0, 400.0, 0.5, 90000.0, 8000.0, 12.5},
+				{logged, "/Root/bob", "bob", "", int64(100), int64(3), 0.75, 4050.0, 3750.0, 300.0, 0.5, 60000.0, 6000.0, 10.0},
+			},
+		}, nil
+	})
+
+	usage, err := d.QueryShareUsage(start, end)
+	if err != nil {
+		t.Fatalf("QueryShareUsage failed: %s", err)
+	}
+	if expected := []driver.Value{start, end}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("got args %v, expected %v", gotArgs, expected)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("got %d records, expected 2", len(usage))
+	}
+	expected := ShareUsage{Time: logged, Node: "/Root/bob", User: "bob", Shares: 100, JobCount: 3, ActualShare: 0.75,
+		Usage: 4050, CPU: 3750, Memory: 300, IO: 0.5, LongTermCPU: 60000, LongTermMemory: 6000, LongTermIO: 10}
+	if !reflect.DeepEqual(usage[1], expected) {
+		t.Errorf("got %+v, expected %+v", usage[1], expected)
+	}
+}
+
+func TestScanNulls(t *testing.T) {
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		switch query {
+		case accountingQuery:
+			running := accountingRow(100, 1, 0)
+			running[7] = nil  // project
+			running[12] = nil // end_time
+			running[19] = nil // exit_status
+			return &fakeRows{columns: accountingColumns, values: [][]driver.Value{running}}, nil
+		case logQuery:
+			return &fakeRows{columns: logColumns, values: [][]driver.Value{
+				{int64(100), int64(1), "NONE", "render", "bob", nil, nil, nil,
+					time.Date(2012, 11, 1, 12, 0, 0, 0, time.UTC), "pending", "pending", "bob", nil, nil},
+			}}, nil
+		}
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	})
+
+	as, err := d.QueryAccounting(100)
+	if err != nil {
+		t.Fatalf("QueryAccounting failed: %s", err)
+	}
+	if len(as) != 1 {
+		t.Fatalf("got %d records, expected 1", len(as))
+	}
+	if a := as[0]; !a.EndTime.IsZero() || a.ExitStatus != 0 || a.Project != "" || a.Username != "bob" || a.CPU != 3000.5 {
+		t.Errorf("unexpected record: %+v", a)
+	}
+
+	logs, err := d.QueryLogs(100, 1)
+	if err != nil {
+		t.Fatalf("QueryLogs failed: %s", err)
+	}
+	if len(logs) != 1 || logs[0].Host != "" || logs[0].Account != "" || logs[0].Event != "pending" {
+		t.Errorf("unexpected log entries: %+v", logs)
+	}
+}
+
+func TestQueryDistinct(t *testing.T) {
+	start := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	var gotQuery string
+	var gotArgs []driver.Value
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		gotQuery, gotArgs = query, args
+		return &fakeRows{columns: []string{"name"}, values: [][]driver.Value{{"a"}, {"b"}}}, nil
+	})
+
+	tests := []struct {
+		name   string
+		query  func(time.Time, time.Time) ([]string, error)
+		column string
+	}{
+		{"users", d.QueryUsers, "username"},
+		{"projects", d.QueryProjects, "project"},
+		{"departments", d.QueryDepartments, "department"},
+	}
+	for _, test := range tests {
+		names, err := test.query(start, end)
+		if err != nil {
+			t.Errorf("%s: query failed: %s", test.name, err)
+			continue
+		}
+		if expected := []string{"a", "b"}; !reflect.DeepEqual(names, expected) {
+			t.Errorf("%s: got %q, expected %q", test.name, names, expected)
+		}
+		if !strings.HasPrefix(gotQuery, "SELECT DISTINCT "+test.column+"\n") || !strings.HasSuffix(gotQuery, "ORDER BY "+test.column) {
+			t.Errorf("%s: unexpected query: %s", test.name, gotQuery)
+		}
+		if expected := []driver.Value{start, end}; !reflect.DeepEqual(gotArgs, expected) {
+			t.Errorf("%s: got args %v, expected %v", test.name, gotArgs, expected)
+		}
+	}
+
+	// Zero times leave the period open
+	if _, err := d.QueryUsers(time.Time{}, end); err != nil {
+		t.Fatalf("QueryUsers failed: %s", err)
+	}
+	if expected := []driver.Value{nil, end}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("open start: got args %v, expected %v", gotArgs, expected)
+	}
+	if _, err := d.QueryUsers(time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("QueryUsers failed: %s", err)
+	}
+	if expected := []driver.Value{nil, nil}; !reflect.DeepEqual(gotArgs, expected) {
+		t.Errorf("open period: got args %v, expected %v", gotArgs, expected)
+	}
+}
+
+func TestQueryAdvanceReservationsRescheduled(t *testing.T) {
+	// Reservation 9 was first made for the morning of November 1st, then moved to the next day
+	day := time.Date(2012, 11, 1, 0, 0, 0, 0, time.UTC)
+	type attributes struct {
+		written    time.Time
+		start, end time.Time
+	}
+	records := []attributes{
+		{day.Add(-time.Hour), day.Add(9 * time.Hour), day.Add(11 * time.Hour)},
+		{day.Add(8 * time.Hour), day.Add(33 * time.Hour), day.Add(35 * time.Hour)},
+	}
+
+	// The fake database filters the attribute records by time either before or after choosing the latest,
+	// depending on where the query compares them with the period
+	d := newTestDB(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if query != reservationsQuery {
+			return &fakeRows{columns: []string{"arru_variable", "arru_value"}}, nil
+		}
+		start, end := args[0].(time.Time), args[1].(time.Time)
+		overlaps := func(a attributes) bool { return a.start.Before(end) && a.end.After(start) }
+		sub := strings.Index(query, ") AS latest")
+		latestFirst := sub >= 0 && sub < strings.Index(query, "ara_start_time < $2")
+
+		var candidates []attributes
+		for _, a := range records {
+			if latestFirst || overlaps(a) {
+				candidates = append(candidates, a)
+			}
+		}
+		r := &fakeRows{columns: reservationColumns}
+		if len(candidates) == 0 {
+			return r, nil
+		}
+		latest := candidates[0]
+		for _, a := range candidates[1:] {
+			if a.written.After(latest.written) {
+				latest = a
+			}
+		}
+		if !latestFirst || overlaps(latest) {
+			r.values = [][]driver.Value{{int64(9), "bob", day, "maintenance", "sge", latest.start, latest.end, ""}}
+		}
+		return r, nil
+	})
+
+	ars, err := d.QueryAdvanceReservations(day, day.Add(12*time.Hour))
+	if err != nil {
+		t.Fatalf("QueryAdvanceReservations failed: %s", err)
+	}
+	if len(ars) != 0 {
+		t.Errorf("got %+v, expected the rescheduled reservation to be left out", ars)
+	}
+
+	ars, err = d.QueryAdvanceReservations(day.Add(24*time.Hour), day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("QueryAdvanceReservations failed: %s", err)
+	}
+	if len(ars) != 1 || !ars[0].StartTime.Equal(records[1].start) {
+		t.Errorf("got %+v, expected the reservation at its rescheduled time", ars)
+	}
+}
+
+// TestNullableFields scans a value and a NULL in to every field of the records scanned through
+// nullables, so a field of a type nullable does not handle fails here rather than in a query.
+func TestNullableFields(t *testing.T) {
+	when := time.Date(2012, 11, 1, 12, 0, 0, 0, time.UTC)
+	values := map[reflect.Type]driver.Value{
+		reflect.TypeOf(""):   "x",
+		reflect.TypeOf(0):    int64(7),
+		reflect.TypeOf(0.0):  1.5,
+		reflect.TypeOf(when): when,
+	}
+	expected := map[reflect.Type]interface{}{
+		reflect.TypeOf(""):   "x",
+		reflect.TypeOf(0):    7,
+		reflect.TypeOf(0.0):  1.5,
+		reflect.TypeOf(when): when,
+	}
+	for _, record := range []interface{}{&Job{}, &Accounting{}, &Log{}} {
+		r := reflect.ValueOf(record).Elem()
+		for i := 0; i < r.NumField(); i++ {
+			f := r.Field(i)
+			name := r.Type().Name() + "." + r.Type().Field(i).Name
+			v, ok := values[f.Type()]
+			if !ok {
+				t.Errorf("%s: no test value for %s", name, f.Type())
+				continue
+			}
+			n := nullable{f.Addr().Interface()}
+			if err := n.Scan(v); err != nil {
+				t.Errorf("%s: scanning %v failed: %s", name, v, err)
+				continue
+			}
+			if got := f.Interface(); got != expected[f.Type()] {
+				t.Errorf("%s: got %v, expected %v", name, got, expected[f.Type()])
+			}
+			if err := n.Scan(nil); err != nil {
+				t.Errorf("%s: scanning NULL failed: %s", name, err)
+			}
+		}
+	}
+}
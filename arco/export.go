@@ -0,0 +1,187 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arco
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+	labelEscaper       = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// WriteAccountingLineProtocol writes the accounting records in InfluxDB line protocol to w,
+// one point per record in the given measurement. Each point is tagged with the user, project, department
+// and queue of the job, and timestamped with its end time. Empty tag values are omitted, since line protocol
+// does not allow them. Records without an end time, such as those of jobs still running, are skipped,
+// since their points would have no timestamp.
+func WriteAccountingLineProtocol(w io.Writer, as []Accounting, measurement string) error {
+	m := measurementEscaper.Replace(measurement)
+	for _, a := range as {
+		if a.EndTime.IsZero() {
+			continue
+		}
+		line := m
+		for _, tag := range []struct{ key, value string }{
+			{"user", a.Username},
+			{"project", a.Project},
+			{"department", a.Department},
+			{"queue", a.Queue},
+		} {
+			if tag.value != "" {
+				line += "," + tag.key + "=" + tagEscaper.Replace(tag.value)
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s job_number=%di,task_number=%di,cpu=%s,mem=%s,io=%s,wallclock=%di,maxvmem=%s,exit_status=%di %d\n",
+			line, a.JobNumber, a.TaskNumber, formatFloat(a.CPU), formatFloat(a.Memory), formatFloat(a.IO),
+			a.WallClockTime, formatFloat(a.MaxVMem), a.ExitStatus, a.EndTime.UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatFloat formats f without an exponent.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// usageMetrics are the metrics written by WriteAccountingMetrics
+var usageMetrics = []struct {
+	name  string
+	help  string
+	value func(UsageSummary) float64
+}{
+	{"gorge_usage_jobs_total", "Number of finished jobs.", func(s UsageSummary) float64 { return float64(s.Jobs) }},
+	{"gorge_usage_cpu_seconds_total", "CPU time used by finished jobs in seconds.", func(s UsageSummary) float64 { return s.CPU }},
+	{"gorge_usage_memory_gigabyte_seconds_total", "Integral memory usage of finished jobs in gigabyte seconds.", func(s UsageSummary) float64 { return s.Memory }},
+	{"gorge_usage_io_gigabytes_total", "Data transferred by finished jobs in gigabytes.", func(s UsageSummary) float64 { return s.IO }},
+	{"gorge_usage_wallclock_seconds_total", "Wall clock time of finished jobs in seconds.", func(s UsageSummary) float64 { return s.WallClock }},
+}
+
+// WriteAccountingMetrics writes the usage summaries to w as gauges in the Prometheus text exposition format,
+// suitable for pushing to a Pushgateway. Each summary is labelled with its GroupBy dimension and key,
+// for example gorge_usage_cpu_seconds_total{user="bob"}. It is an error, and nothing is written, if a summary's
+// GroupBy is not one of GroupByUser, GroupByProject or GroupByDepartment.
+func WriteAccountingMetrics(w io.Writer, summaries []UsageSummary) error {
+	for _, s := range summaries {
+		if _, ok := usageColumns[s.GroupBy]; !ok {
+			return fmt.Errorf("arco: cannot write metrics for usage summarized by %q", s.GroupBy)
+		}
+	}
+	for _, m := range usageMetrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name); err != nil {
+			return err
+		}
+		for _, s := range summaries {
+			_, err := fmt.Fprintf(w, "%s{%s=\"%s\"} %s\n", m.name, s.GroupBy, labelEscaper.Replace(s.Key), formatFloat(m.value(s)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatTime formats t in RFC 3339 format, or as an empty string if t is the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// csvColumns are the columns written by WriteAccountingCSV, named like the JSON fields of Accounting
+var csvColumns = []struct {
+	name  string
+	value func(Accounting) string
+}{
+	{"jobNumber", func(a Accounting) string { return strconv.Itoa(a.JobNumber) }},
+	{"taskNumber", func(a Accounting) string { return strconv.Itoa(a.TaskNumber) }},
+	{"peTaskId", func(a Accounting) string { return a.PETaskId }},
+	{"jobName", func(a Accounting) string { return a.Name }},
+	{"group", func(a Accounting) string { return a.Group }},
+	{"userName", func(a Accounting) string { return a.Username }},
+	{"account", func(a Accounting) string { return a.Account }},
+	{"project", func(a Accounting) string { return a.Project }},
+	{"department", func(a Accounting) string { return a.Department }},
+	{"submissionTime", func(a Accounting) string { return formatTime(a.SubmissionTime) }},
+	{"arParent", func(a Accounting) string { return strconv.Itoa(a.ARParent) }},
+	{"startTime", func(a Accounting) string { return formatTime(a.StartTime) }},
+	{"endTime", func(a Accounting) string { return formatTime(a.EndTime) }},
+	{"wallClockTime", func(a Accounting) string { return strconv.Itoa(a.WallClockTime) }},
+	{"cpu", func(a Accounting) string { return formatFloat(a.CPU) }},
+	{"memory", func(a Accounting) string { return formatFloat(a.Memory) }},
+	{"io", func(a Accounting) string { return formatFloat(a.IO) }},
+	{"ioWait", func(a Accounting) string { return formatFloat(a.IOWait) }},
+	{"maxVmem", func(a Accounting) string { return formatFloat(a.MaxVMem) }},
+	{"exitStatus", func(a Accounting) string { return strconv.Itoa(a.ExitStatus) }},
+	{"maxRss", func(a Accounting) string { return strconv.Itoa(a.MaxRSS) }},
+	{"host", func(a Accounting) string { return a.Host }},
+	{"queue", func(a Accounting) string { return a.Queue }},
+}
+
+// WriteAccountingCSV writes the accounting records to w as CSV, after a header row naming the columns
+// like the JSON fields of Accounting. Times are written in RFC 3339 format; missing times are left empty.
+func WriteAccountingCSV(w io.Writer, as []Accounting) error {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(csvColumns))
+	for i, c := range csvColumns {
+		record[i] = c.name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, a := range as {
+		for i, c := range csvColumns {
+			record[i] = c.value(a)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonlRecord is an Accounting as written by WriteAccountingJSONL. Its times shadow those of the Accounting,
+// formatted as for WriteAccountingCSV or null if missing.
+type jsonlRecord struct {
+	Accounting
+	SubmissionTime *string `json:"submissionTime"`
+	StartTime      *string `json:"startTime"`
+	EndTime        *string `json:"endTime"`
+}
+
+// jsonlTime returns t formatted by formatTime, or nil if t is the zero time
+func jsonlTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	s := formatTime(t)
+	return &s
+}
+
+// WriteAccountingJSONL writes the accounting records to w as JSON lines, one JSON object per line
+// with the fields of Accounting. Times are written in RFC 3339 format like WriteAccountingCSV, and missing times as null.
+func WriteAccountingJSONL(w io.Writer, as []Accounting) error {
+	enc := json.NewEncoder(w)
+	for _, a := range as {
+		r := jsonlRecord{a, jsonlTime(a.SubmissionTime), jsonlTime(a.StartTime), jsonlTime(a.EndTime)}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
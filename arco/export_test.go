@@ -0,0 +1,121 @@
+package arco
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestWriteAccountingLineProtocol(t *testing.T) {
+	end := time.Date(2012, 11, 1, 13, 0, 0, 0, time.UTC)
+	as := []Accounting{
+		{
+			JobNumber: 100, TaskNumber: 1, Username: "bob", Project: "some project", Department: "a,b=c",
+			EndTime: end, WallClockTime: 3540, CPU: 3000.5, Memory: 120.25, IO: 1.5, MaxVMem: 1073741824,
+		},
+		{
+			JobNumber: 101, TaskNumber: 2, Username: "john", Department: "defaultdepartment",
+			EndTime: end.Add(time.Second), WallClockTime: 60, CPU: 59, ExitStatus: 137,
+		},
+		{
+			JobNumber: 102, TaskNumber: 1, Username: "bob", Queue: "all queue",
+			EndTime: end.Add(2 * time.Second), WallClockTime: 30, CPU: 29.5,
+		},
+		{JobNumber: 103, TaskNumber: 1, Username: "bob", CPU: 10},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAccountingLineProtocol(&buf, as, "sge accounting"); err != nil {
+		t.Fatalf("WriteAccountingLineProtocol failed: %s", err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/accounting.lp")
+	if err != nil {
+		t.Fatalf("could not read golden file: %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.Bytes(), golden)
+	}
+}
+
+func TestWriteAccountingMetrics(t *testing.T) {
+	summaries := []UsageSummary{
+		{GroupBy: GroupByUser, Key: "bob", Jobs: 2, CPU: 3059.5, Memory: 120.25, IO: 1.5, WallClock: 3600},
+		{GroupBy: GroupByProject, Key: `a "quoted" \ project`, Jobs: 1, CPU: 59, WallClock: 60},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAccountingMetrics(&buf, summaries); err != nil {
+		t.Fatalf("WriteAccountingMetrics failed: %s", err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/usage.prom")
+	if err != nil {
+		t.Fatalf("could not read golden file: %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.Bytes(), golden)
+	}
+}
+
+func TestWriteAccountingMetricsGroupBy(t *testing.T) {
+	for _, groupBy := range []string{"", "queue", "user label"} {
+		var buf bytes.Buffer
+		summaries := []UsageSummary{{GroupBy: GroupByUser, Key: "bob", Jobs: 1}, {GroupBy: groupBy, Key: "x", Jobs: 1}}
+		if err := WriteAccountingMetrics(&buf, summaries); err == nil {
+			t.Errorf("%q: expected an error", groupBy)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%q: got output %q, expected none", groupBy, buf.Bytes())
+		}
+	}
+}
+
+// exportRecords are the accounting records written by the CSV and JSON lines export tests
+var exportRecords = []Accounting{
+	{
+		JobNumber: 100, TaskNumber: 1, PETaskId: "NONE", Name: "render", Group: "users", Username: "bob",
+		Account: "sge", Project: "some, project", Department: "defaultdepartment",
+		SubmissionTime: time.Date(2012, 11, 1, 12, 0, 0, 0, time.UTC),
+		StartTime:      time.Date(2012, 11, 1, 12, 1, 0, 0, time.UTC),
+		EndTime:        time.Date(2012, 11, 1, 13, 0, 0, 0, time.UTC),
+		WallClockTime:  3540, CPU: 3000.5, Memory: 120.25, IO: 1.5, IOWait: 0.5, MaxVMem: 1073741824, MaxRSS: 524288,
+		Host: "node01", Queue: "all.q",
+	},
+	{
+		JobNumber: 101, TaskNumber: 2, Name: `say "hi"`, Username: "john",
+		SubmissionTime: time.Date(2012, 11, 1, 12, 30, 0, 0, time.FixedZone("PST", -8*60*60)),
+		ExitStatus:     137,
+	},
+}
+
+func TestWriteAccountingCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAccountingCSV(&buf, exportRecords); err != nil {
+		t.Fatalf("WriteAccountingCSV failed: %s", err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/accounting.csv")
+	if err != nil {
+		t.Fatalf("could not read golden file: %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.Bytes(), golden)
+	}
+}
+
+func TestWriteAccountingJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAccountingJSONL(&buf, exportRecords); err != nil {
+		t.Fatalf("WriteAccountingJSONL failed: %s", err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/accounting.jsonl")
+	if err != nil {
+		t.Fatalf("could not read golden file: %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), golden) {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.Bytes(), golden)
+	}
+}
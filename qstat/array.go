@@ -0,0 +1,47 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+)
+
+// summaryState returns the state a job is counted in by ArrayTaskSummary.
+// States which take precedence, such as an error, are checked first.
+func (j QueueJob) summaryState() string {
+	switch st := j.ParsedState(); {
+	case st.Error:
+		return "error"
+	case st.Deletion:
+		return "deleted"
+	case st.Suspended, st.QueueSuspended, st.Threshold:
+		return "suspended"
+	case st.Running, st.Transferring:
+		return "running"
+	case st.Hold:
+		return "hold"
+	default:
+		return "pending"
+	}
+}
+
+// ArrayTaskSummary returns the number of tasks of the array job jobNumber in each state, from a single
+// run of qstat -g d. The states are "running", "pending", "hold", "suspended", "deleted" and "error".
+// Only states that some task is in are included.
+func (c *Client) ArrayTaskSummary(jobNumber int) (map[string]int, error) {
+	q := new(QueueInfo)
+	if err := c.qstat(context.Background(), q, "-g", "d", "-u", "*"); err != nil {
+		return nil, err
+	}
+	summary := make(map[string]int)
+	for _, js := range [][]QueueJob{q.QueuedJobs, q.PendingJobs} {
+		for _, j := range js {
+			if j.JobNumber == jobNumber {
+				summary[j.summaryState()] += j.NumTasks()
+			}
+		}
+	}
+	return summary, nil
+}
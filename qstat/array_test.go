@@ -0,0 +1,80 @@
+package qstat
+
+import (
+	"reflect"
+	"testing"
+)
+
+const expandedArrayQueueInfo = `<?xml version='1.0'?>
+<job_info>
+  <queue_info>
+    <job_list state="running">
+      <JB_job_number>500</JB_job_number>
+      <state>r</state>
+      <queue_name>all.q@node01</queue_name>
+      <tasks>1</tasks>
+    </job_list>
+    <job_list state="running">
+      <JB_job_number>500</JB_job_number>
+      <state>t</state>
+      <queue_name>all.q@node02</queue_name>
+      <tasks>2</tasks>
+    </job_list>
+    <job_list state="running">
+      <JB_job_number>500</JB_job_number>
+      <state>dr</state>
+      <queue_name>all.q@node02</queue_name>
+      <tasks>3</tasks>
+    </job_list>
+    <job_list state="running">
+      <JB_job_number>500</JB_job_number>
+      <state>S</state>
+      <queue_name>all.q@node03</queue_name>
+      <tasks>4</tasks>
+    </job_list>
+    <job_list state="running">
+      <JB_job_number>501</JB_job_number>
+      <state>r</state>
+      <queue_name>all.q@node03</queue_name>
+    </job_list>
+  </queue_info>
+  <job_info>
+    <job_list state="pending">
+      <JB_job_number>500</JB_job_number>
+      <state>Eqw</state>
+      <tasks>5</tasks>
+    </job_list>
+    <job_list state="pending">
+      <JB_job_number>500</JB_job_number>
+      <state>hqw</state>
+      <tasks>6</tasks>
+    </job_list>
+    <job_list state="pending">
+      <JB_job_number>500</JB_job_number>
+      <state>qw</state>
+      <tasks>7-20:1</tasks>
+    </job_list>
+  </job_info>
+</job_info>
+`
+
+func TestArrayTaskSummary(t *testing.T) {
+	r := &fakeRunner{output: []byte(expandedArrayQueueInfo)}
+	c := &Client{Runner: r}
+	summary, err := c.ArrayTaskSummary(500)
+	if err != nil {
+		t.Fatalf("ArrayTaskSummary failed: %s", err)
+	}
+	expected := map[string]int{"running": 2, "deleted": 1, "suspended": 1, "error": 1, "hold": 1, "pending": 14}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("got %v, expected %v", summary, expected)
+	}
+	args := []string{"-xml", "-g", "d", "-u", "*"}
+	if cmd := r.commands[0]; cmd.name != "qstat" || !reflect.DeepEqual(cmd.args, args) {
+		t.Errorf("ran %s %q, expected qstat %q", cmd.name, cmd.args, args)
+	}
+
+	if summary, err := c.ArrayTaskSummary(999); err != nil || len(summary) != 0 {
+		t.Errorf("got %v, %v, expected an empty summary", summary, err)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kisielk/gorge/util"
+)
+
+// Runner runs a GridEngine command.
+// Run starts the command and returns its standard output. Closing the output waits for the
+// command to exit and returns an error if it could not be run to completion.
+// If the output also implements Stderrer, anything the command printed to its standard error
+// while exiting successfully is reported as warnings.
+type Runner interface {
+	Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error)
+}
+
+// Stderrer is implemented by command output that captures the command's standard error.
+// Stderr returns the captured text and is valid after the output is closed.
+type Stderrer interface {
+	Stderr() string
+}
+
+// warnings returns the lines out wrote to standard error, if it implements Stderrer
+func warnings(out io.ReadCloser) []string {
+	s, ok := out.(Stderrer)
+	if !ok {
+		return nil
+	}
+	var ws []string
+	for _, line := range strings.Split(s.Stderr(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			ws = append(ws, line)
+		}
+	}
+	return ws
+}
+
+// ErrQstatNotFound is returned when qstat, or another GridEngine command, is not installed.
+// A command run through a shell, such as with SSHRunner, that exits with the shell's
+// command not found status of 127 also matches it with errors.Is.
+var ErrQstatNotFound = errors.New("qstat: binary not found in PATH")
+
+// LocalRunner is a Runner that runs commands on the local host.
+type LocalRunner struct{}
+
+// Run implements the Runner interface
+func (LocalRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	// Don't wait forever for stdout to be closed by any children the command left running after it was killed
+	cmd.WaitDelay = time.Second
+	p := &process{cmd: cmd}
+	cmd.Stderr = &p.stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not get stdout: %s", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrQstatNotFound, name)
+		}
+		return nil, fmt.Errorf("%s: could not start %s: %s", name, name, err)
+	}
+	p.stdout = stdout
+	return p, nil
+}
+
+// process is the output of a command started by LocalRunner
+type process struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+}
+
+func (p *process) Read(b []byte) (int, error) {
+	return p.stdout.Read(b)
+}
+
+// Stderr implements the Stderrer interface
+func (p *process) Stderr() string {
+	return p.stderr.String()
+}
+
+// Close discards any unread output and waits for the command to exit.
+// If the command exits with a non-zero status the error is a *QstatError.
+func (p *process) Close() error {
+	io.Copy(ioutil.Discard, p.stdout)
+	err := p.cmd.Wait()
+	if ee, ok := err.(*exec.ExitError); ok {
+		return &QstatError{
+			ExitCode: ee.ExitCode(),
+			Stderr:   strings.TrimSpace(p.stderr.String()),
+			Args:     p.cmd.Args,
+		}
+	} else if err != nil {
+		return fmt.Errorf("%s: %s", p.cmd.Args[0], err)
+	}
+	return nil
+}
+
+// QstatError is returned when qstat, or another GridEngine command, runs but exits with a non-zero status.
+type QstatError struct {
+	ExitCode int      // The exit status of the command, -1 if it was killed by a signal
+	Stderr   string   // What the command printed to standard error
+	Args     []string // The command line, starting with the command name
+}
+
+// Is reports whether the command exited with status 127, which shells use for a command that was not found.
+func (e *QstatError) Is(target error) bool {
+	return target == ErrQstatNotFound && e.ExitCode == 127
+}
+
+func (e *QstatError) Error() string {
+	msg := fmt.Sprintf("exit status %d", e.ExitCode)
+	if e.ExitCode < 0 {
+		msg = "killed"
+	}
+	name := "qstat"
+	if len(e.Args) > 0 {
+		name = filepath.Base(e.Args[0])
+	}
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s: %s: %s", name, msg, e.Stderr)
+	}
+	return fmt.Sprintf("%s: %s", name, msg)
+}
+
+// JobError is returned when a command acting on several jobs, such as qdel, fails for some of them
+type JobError struct {
+	Command string         // The command name, eg: qdel
+	Action  string         // What the command could not do, eg: could not delete, or empty
+	Jobs    map[int]string // The message the command printed for each job it failed for, keyed by job number
+	Err     error          // The error the command failed with, a *QstatError if it exited with a non-zero status
+}
+
+func (e *JobError) Error() string {
+	var ns []int
+	for n := range e.Jobs {
+		ns = append(ns, n)
+	}
+	sort.Ints(ns)
+	var msgs []string
+	for _, n := range ns {
+		msgs = append(msgs, fmt.Sprintf("job %d: %s", n, e.Jobs[n]))
+	}
+	prefix := e.Command + ": "
+	if e.Action != "" {
+		prefix += e.Action + " "
+	}
+	return prefix + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the error the command failed with
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// numbers matches the numbers in a message
+var numbers = regexp.MustCompile(`[0-9]+`)
+
+// jobErrors returns the error of the named command acting on jobNumbers, as a *JobError if any of the lines of
+// its output that don't match succeeded name one of the jobs. action describes what the command failed
+// to do in the message of the JobError. out is the command's standard output and err the error it failed
+// with, if any.
+func jobErrors(name, action string, jobNumbers []int, succeeded *regexp.Regexp, out []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	lines := string(out)
+	var qerr *QstatError
+	if errors.As(err, &qerr) {
+		lines += "\n" + qerr.Stderr
+	}
+	requested := make(map[string]int)
+	for _, n := range jobNumbers {
+		requested[strconv.Itoa(n)] = n
+	}
+	failed := make(map[int]string)
+	for _, line := range strings.Split(lines, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || succeeded.MatchString(line) {
+			continue
+		}
+		for _, s := range numbers.FindAllString(line, -1) {
+			if n, ok := requested[s]; ok {
+				failed[n] = line
+				break
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return err
+	}
+	return &JobError{name, action, failed, err}
+}
+
+// Client runs GridEngine commands. The zero value runs them on the local host.
+type Client struct {
+	Runner  Runner        // The Runner used to run commands, LocalRunner if nil
+	BinDir  string        // The directory containing the GridEngine commands, eg: /opt/sge/bin/lx-amd64. If empty they are looked up in PATH
+	Timeout time.Duration // If non-zero, commands taking longer are killed and fail with ErrTimeout
+}
+
+// ErrTimeout is returned when a command runs longer than the Client's Timeout
+var ErrTimeout = errors.New("qstat: timed out")
+
+// DefaultClient is the Client used by the package level functions
+var DefaultClient = new(Client)
+
+func (c *Client) runner() Runner {
+	if c.Runner == nil {
+		return LocalRunner{}
+	}
+	return c.Runner
+}
+
+// run runs the named GridEngine command from BinDir using the client's Runner
+func (c *Client) run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if c.BinDir != "" {
+		name = filepath.Join(c.BinDir, name)
+	}
+	if c.Timeout <= 0 {
+		return c.runner().Run(ctx, name, args...)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	out, err := c.runner().Run(ctx, name, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutOutput{out, ctx, cancel, c.Timeout}, nil
+}
+
+// timeoutOutput is the output of a command run with a timeout
+type timeoutOutput struct {
+	io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// Stderr implements the Stderrer interface if the underlying output does
+func (o *timeoutOutput) Stderr() string {
+	if s, ok := o.ReadCloser.(Stderrer); ok {
+		return s.Stderr()
+	}
+	return ""
+}
+
+// Close closes the underlying output, returning ErrTimeout if the command was killed for running too long
+func (o *timeoutOutput) Close() error {
+	err := o.ReadCloser.Close()
+	timedOut := o.ctx.Err() == context.DeadlineExceeded
+	o.cancel()
+	if err != nil && timedOut {
+		return fmt.Errorf("%w after %s", ErrTimeout, o.timeout)
+	}
+	return err
+}
+
+// output runs the named command and returns its complete output.
+func (c *Client) output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := c.run(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(out)
+	if cerr := out.Close(); cerr != nil {
+		return b, cerr
+	}
+	return b, err
+}
+
+// qstat runs qstat -xml with the given arguments and decodes the xml in to result.
+// The output is decoded even if qstat fails, so result holds whatever could be decoded,
+// and anything qstat printed to standard error is included in the returned error.
+func (c *Client) qstat(ctx context.Context, result interface{}, args ...string) error {
+	_, err := c.qstatWarnings(ctx, result, args...)
+	return err
+}
+
+// qstatWarnings is like qstat, but also returns any warnings qstat printed while exiting successfully
+func (c *Client) qstatWarnings(ctx context.Context, result interface{}, args ...string) ([]string, error) {
+	return c.xmlCommand(ctx, "qstat", result, args...)
+}
+
+// xmlCommand runs the named command with -xml and the given arguments and decodes its output
+// in to result as described for qstat, returning any warnings it printed while exiting successfully.
+func (c *Client) xmlCommand(ctx context.Context, name string, result interface{}, args ...string) ([]string, error) {
+	args = append([]string{"-xml"}, args...)
+	out, err := c.run(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(out)
+	_, perr := br.Peek(1)
+	err = DecodeQstat(br, FormatXML, result)
+	if cerr := out.Close(); cerr != nil {
+		// The broken output the command left behind may say more about the failure than its exit status
+		if err != nil && perr == nil {
+			return nil, fmt.Errorf("%w (%s)", cerr, err)
+		}
+		return nil, cerr
+	}
+	ws := warnings(out)
+	if err != nil {
+		if len(ws) > 0 {
+			return nil, fmt.Errorf("%s: %s", err, strings.Join(ws, "; "))
+		}
+		return nil, err
+	}
+	return ws, nil
+}
+
+// QstatRaw runs qstat -xml with the given arguments and returns its output, with any invalid UTF-8 removed
+// as it is before decoding. The output read so far is returned even if qstat fails.
+func (c *Client) QstatRaw(ctx context.Context, args ...string) ([]byte, error) {
+	b, _, err := c.qstatRaw(ctx, args...)
+	return b, err
+}
+
+// qstatRaw is like QstatRaw, but also returns the warnings qstat printed while exiting successfully.
+func (c *Client) qstatRaw(ctx context.Context, args ...string) ([]byte, []string, error) {
+	args = append([]string{"-xml"}, args...)
+	out, err := c.run(ctx, "qstat", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := ioutil.ReadAll(util.NewValidUTF8Reader(out))
+	if cerr := out.Close(); cerr != nil {
+		return b, nil, cerr
+	}
+	if err != nil {
+		return b, nil, fmt.Errorf("qstat: could not read output: %s", err)
+	}
+	return b, warnings(out), nil
+}
+
+// QueueInfoResult is a QueueInfo along with the warnings qstat printed producing it.
+// qstat exiting successfully with output on stderr, such as a notice that some queues are in
+// alarm state, is a warning rather than an error.
+type QueueInfoResult struct {
+	*QueueInfo
+	Warnings []string `json:"warnings"` // The lines qstat printed to stderr
+}
+
+// GetQueueInfoResult is like GetQueueInfo, but also returns the warnings qstat printed.
+func (c *Client) GetQueueInfoResult(ctx context.Context, u string) (*QueueInfoResult, error) {
+	if u == "" {
+		u = "*"
+	}
+	q := new(QueueInfo)
+	ws, err := c.qstatWarnings(ctx, q, "-pri", "-ext", "-urg", "-u", u)
+	if err != nil {
+		return nil, err
+	}
+	return &QueueInfoResult{q, ws}, nil
+}
+
+// queueInfo returns the QueueInfo for user u as described for GetQueueInfo
+func (c *Client) queueInfo(ctx context.Context, u string) (*QueueInfo, error) {
+	res, err := c.GetQueueInfoResult(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return res.QueueInfo, nil
+}
+
+// GetFullQueueInfo returns every queue instance along with the jobs of all users running in it, by running qstat -f.
+func (c *Client) GetFullQueueInfo(ctx context.Context) ([]Queue, error) {
+	q := new(QueueInfo)
+	if err := c.qstat(ctx, q, "-f", "-u", "*"); err != nil {
+		return nil, err
+	}
+	return q.Queues, nil
+}
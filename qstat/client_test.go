@@ -0,0 +1,287 @@
+package qstat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCommand records the invocation of a command run by fakeRunner
+type fakeCommand struct {
+	name string
+	args []string
+}
+
+// fakeRunner is a Runner that returns canned output instead of running commands.
+type fakeRunner struct {
+	output   []byte        // Output returned by every command
+	outputs  [][]byte      // If not empty, the output of successive commands, used before output
+	stderr   string        // Standard error reported by every command
+	err      error         // Error returned when the output is closed
+	commands []fakeCommand // Commands run, in order
+}
+
+func (r *fakeRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	r.commands = append(r.commands, fakeCommand{name, args})
+	out := r.output
+	if len(r.outputs) > 0 {
+		out, r.outputs = r.outputs[0], r.outputs[1:]
+	}
+	return fakeOutput{bytes.NewReader(out), r.stderr, r.err}, nil
+}
+
+type fakeOutput struct {
+	io.Reader
+	stderr string
+	err    error
+}
+
+func (o fakeOutput) Stderr() string {
+	return o.stderr
+}
+
+func (o fakeOutput) Close() error {
+	return o.err
+}
+
+func TestLocalRunner(t *testing.T) {
+	out, err := LocalRunner{}.Run(context.Background(), "echo", "hello")
+	if err != nil {
+		t.Skipf("could not run echo: %s", err)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(out); err != nil {
+		t.Errorf("read failed: %s", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Errorf("close failed: %s", err)
+	}
+	if s := buf.String(); s != "hello\n" {
+		t.Errorf("got output %q, expected %q", s, "hello\n")
+	}
+}
+
+func TestLocalRunnerExitStatus(t *testing.T) {
+	out, err := LocalRunner{}.Run(context.Background(), "sh", "-c", "echo oops >&2; exit 3")
+	if err != nil {
+		t.Skipf("could not run sh: %s", err)
+	}
+	err = out.Close()
+	if err == nil {
+		t.Fatalf("expected error for non-zero exit")
+	}
+	if !strings.Contains(err.Error(), "oops") {
+		t.Errorf("error %q does not include stderr", err)
+	}
+	var qerr *QstatError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("got %T, expected *QstatError", err)
+	}
+	if qerr.ExitCode != 3 || qerr.Stderr != "oops" || !reflect.DeepEqual(qerr.Args, []string{"sh", "-c", "echo oops >&2; exit 3"}) {
+		t.Errorf("unexpected error: %+v", qerr)
+	}
+	if s := qerr.Error(); s != "sh: exit status 3: oops" {
+		t.Errorf("got error string %q", s)
+	}
+}
+
+func TestLocalRunnerContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	out, err := LocalRunner{}.Run(ctx, "sleep", "10")
+	if err != nil {
+		t.Skipf("could not run sleep: %s", err)
+	}
+	start := time.Now()
+	if err := out.Close(); err == nil {
+		t.Errorf("expected error for killed command")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("command was not killed, waited %s", d)
+	}
+}
+
+// useRunner makes DefaultClient run commands with r and returns a function restoring it
+func useRunner(r Runner) (restore func()) {
+	c := DefaultClient
+	DefaultClient = &Client{Runner: r}
+	return func() { DefaultClient = c }
+}
+
+func TestGetQueueInfoContext(t *testing.T) {
+	r := &fakeRunner{output: []byte(queueInfo)}
+	defer useRunner(r)()
+
+	q, err := GetQueueInfoContext(context.Background(), "bob")
+	if err != nil {
+		t.Fatalf("GetQueueInfoContext failed: %s", err)
+	}
+	if len(q.QueuedJobs) != 1 || len(q.PendingJobs) != 1 {
+		t.Errorf("unexpected queue info: %+v", q)
+	}
+	args := []string{"-xml", "-pri", "-ext", "-urg", "-u", "bob"}
+	if cmd := r.commands[0]; cmd.name != "qstat" || !reflect.DeepEqual(cmd.args, args) {
+		t.Errorf("ran %s %q, expected qstat %q", cmd.name, cmd.args, args)
+	}
+}
+
+func TestGetQueueInfoResult(t *testing.T) {
+	r := &fakeRunner{
+		output: []byte(queueInfo),
+		stderr: "warning: some queues are in alarm state\n\nwarning: all.q@node01 is unreachable\n",
+	}
+	c := &Client{Runner: r}
+	res, err := c.GetQueueInfoResult(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetQueueInfoResult failed: %s", err)
+	}
+	if len(res.QueuedJobs) != 1 || len(res.PendingJobs) != 1 {
+		t.Errorf("unexpected queue info: %+v", res.QueueInfo)
+	}
+	expected := []string{"warning: some queues are in alarm state", "warning: all.q@node01 is unreachable"}
+	if !reflect.DeepEqual(res.Warnings, expected) {
+		t.Errorf("got warnings %q, expected %q", res.Warnings, expected)
+	}
+
+	r.stderr = ""
+	if res, err := c.GetQueueInfoResult(context.Background(), "bob"); err != nil || res.Warnings != nil {
+		t.Errorf("got %v, %v, expected no warnings", res.Warnings, err)
+	}
+}
+
+func TestClientBinDir(t *testing.T) {
+	r := &fakeRunner{output: []byte(queueInfo)}
+	c := &Client{Runner: r, BinDir: "/opt/sge/bin/lx-amd64"}
+	if _, err := c.GetQueueInfoResult(context.Background(), ""); err != nil {
+		t.Fatalf("GetQueueInfoResult failed: %s", err)
+	}
+	if name := r.commands[0].name; name != "/opt/sge/bin/lx-amd64/qstat" {
+		t.Errorf("ran %s, expected /opt/sge/bin/lx-amd64/qstat", name)
+	}
+}
+
+// writeScript writes an executable shell script called name in to dir
+func writeScript(t *testing.T, dir, name, script string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("could not write %s: %s", name, err)
+	}
+}
+
+func TestQstatStderr(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	c := &Client{BinDir: dir}
+
+	tests := []struct {
+		name     string
+		script   string
+		exitCode int // The exit code of the expected QstatError, 0 if qstat does not fail
+	}{
+		{"failed", "echo 'error: invalid option -z' >&2\nexit 2\n", 2},
+		{"failed with output", "echo '<job_info>'\necho 'error: invalid option -z' >&2\nexit 2\n", 2},
+		{"broken output", "echo '<job_info><'\necho 'error: invalid option -z' >&2\n", 0},
+	}
+	for _, test := range tests {
+		writeScript(t, dir, "qstat", test.script)
+		var q QueueInfo
+		err := c.qstat(context.Background(), &q, "-z")
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), "error: invalid option -z") {
+			t.Errorf("%s: error %q does not include stderr", test.name, err)
+		}
+		var qerr *QstatError
+		if !errors.As(err, &qerr) {
+			if test.exitCode != 0 {
+				t.Errorf("%s: got %T, expected *QstatError", test.name, err)
+			}
+			continue
+		}
+		if test.exitCode == 0 {
+			t.Errorf("%s: got %v, expected qstat not to fail", test.name, qerr)
+			continue
+		}
+		if qerr.ExitCode != test.exitCode || qerr.Stderr != "error: invalid option -z" {
+			t.Errorf("%s: got exit code %d and stderr %q, expected %d and %q", test.name, qerr.ExitCode, qerr.Stderr, test.exitCode, "error: invalid option -z")
+		}
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	// The background sleep holds stdout open after qstat itself is killed
+	writeScript(t, dir, "qstat", "sleep 10 &\nsleep 10\n")
+	c := &Client{BinDir: dir, Timeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	var q QueueInfo
+	err := c.qstat(context.Background(), &q)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("got error %v, expected ErrTimeout", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("timed out command took %s to return", d)
+	}
+
+	writeScript(t, dir, "qstat", "echo '<job_info></job_info>'\n")
+	if err := c.qstat(context.Background(), &q); err != nil {
+		t.Errorf("qstat within the timeout failed: %s", err)
+	}
+}
+
+func TestErrQstatNotFound(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := new(Client).run(context.Background(), "gorge-no-such-command"); !errors.Is(err, ErrQstatNotFound) {
+		t.Errorf("PATH: got error %v, expected ErrQstatNotFound", err)
+	}
+	var q QueueInfo
+	if err := (&Client{BinDir: dir}).qstat(context.Background(), &q); !errors.Is(err, ErrQstatNotFound) {
+		t.Errorf("BinDir: got error %v, expected ErrQstatNotFound", err)
+	}
+
+	r := &fakeRunner{err: &QstatError{ExitCode: 127, Stderr: "sh: qstat: command not found", Args: []string{"ssh"}}}
+	if err := (&Client{Runner: r}).qstat(context.Background(), &q); !errors.Is(err, ErrQstatNotFound) {
+		t.Errorf("remote: got error %v, expected ErrQstatNotFound", err)
+	}
+	r.err = &QstatError{ExitCode: 1}
+	if err := (&Client{Runner: r}).qstat(context.Background(), &q); errors.Is(err, ErrQstatNotFound) {
+		t.Errorf("exit status 1 matched ErrQstatNotFound")
+	}
+}
+
+func TestQstatRaw(t *testing.T) {
+	r := &fakeRunner{output: []byte("<job_info><JB_name>caf\xe9</JB_name></job_info>\n")}
+	defer useRunner(r)()
+	b, err := QstatRaw("-u", "bob")
+	if err != nil {
+		t.Fatalf("QstatRaw failed: %s", err)
+	}
+	if s := string(b); s != "<job_info><JB_name>caf</JB_name></job_info>\n" {
+		t.Errorf("got %q, invalid UTF-8 was not removed", s)
+	}
+	args := []string{"-xml", "-u", "bob"}
+	if cmd := r.commands[0]; cmd.name != "qstat" || !reflect.DeepEqual(cmd.args, args) {
+		t.Errorf("ran %s %q, expected qstat %q", cmd.name, cmd.args, args)
+	}
+
+	r.err = errors.New("qstat: exit status 1")
+	if b, err := QstatRaw(); err == nil || len(b) == 0 {
+		t.Errorf("got %q, %v, expected output and an error", b, err)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"strconv"
+)
+
+// ClusterQueueSummary is the slot usage of a cluster queue across all of its queue instances,
+// as shown by qstat -g c.
+type ClusterQueueSummary struct {
+	Name         string  `json:"name"`         // The cluster queue name
+	LoadAvg      float64 `json:"loadAvg"`      // The average normalized load of the queue's hosts, -1 if unknown
+	Used         int     `json:"used"`         // Slots used by running jobs
+	Reserved     int     `json:"reserved"`     // Slots reserved for pending jobs
+	Available    int     `json:"available"`    // Slots free to run jobs
+	Total        int     `json:"total"`        // Total slots
+	TempDisabled int     `json:"tempDisabled"` // Slots of instances in alarm, orphaned, or disabled or suspended by a calendar or another queue
+	Manual       int     `json:"manual"`       // Slots of instances needing manual intervention, that is disabled, suspended, unknown or in error
+	Suspended    int     `json:"suspended"`    // Slots of suspended instances, for any reason
+}
+
+// clusterQueueSummaryXML is a cluster_queue_summary element of qstat -g c -ext -xml
+type clusterQueueSummaryXML struct {
+	Name                 string `xml:"name"`
+	Load                 string `xml:"load"`
+	Used                 int    `xml:"used"`
+	Reserved             int    `xml:"resv"`
+	Available            int    `xml:"available"`
+	Total                int    `xml:"total"`
+	TempDisabled         int    `xml:"temp_disabled"`
+	ManualIntervention   int    `xml:"manual_intervention"`
+	SuspendManual        int    `xml:"suspend_manual"`
+	SuspendThreshold     int    `xml:"suspend_threshold"`
+	SuspendOnSubordinate int    `xml:"suspend_on_subordinate"`
+	SuspendCalendar      int    `xml:"suspend_calendar"`
+}
+
+// GetClusterQueueSummary returns the slot usage of every cluster queue, by running qstat -g c
+func (c *Client) GetClusterQueueSummary(ctx context.Context) ([]ClusterQueueSummary, error) {
+	var result struct {
+		Queues []clusterQueueSummaryXML `xml:"cluster_queue_summary"`
+	}
+	if err := c.qstat(ctx, &result, "-g", "c", "-ext"); err != nil {
+		return nil, err
+	}
+	var qs []ClusterQueueSummary
+	for _, q := range result.Queues {
+		load, err := strconv.ParseFloat(q.Load, 64)
+		if err != nil {
+			// Queues whose hosts report no load show -NA-
+			load = -1
+		}
+		qs = append(qs, ClusterQueueSummary{
+			Name:         q.Name,
+			LoadAvg:      load,
+			Used:         q.Used,
+			Reserved:     q.Reserved,
+			Available:    q.Available,
+			Total:        q.Total,
+			TempDisabled: q.TempDisabled,
+			Manual:       q.ManualIntervention,
+			Suspended:    q.SuspendManual + q.SuspendThreshold + q.SuspendOnSubordinate + q.SuspendCalendar,
+		})
+	}
+	return qs, nil
+}
+
+// GetClusterQueueSummary returns the slot usage of every cluster queue using DefaultClient
+func GetClusterQueueSummary() ([]ClusterQueueSummary, error) {
+	return DefaultClient.GetClusterQueueSummary(context.Background())
+}
@@ -0,0 +1,73 @@
+package qstat
+
+import (
+	"reflect"
+	"testing"
+)
+
+const clusterQueueSummary = `<?xml version='1.0'?>
+<job_info  xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qstat/qstat.xsd?revision=1.11">
+  <cluster_queue_summary>
+    <name>all.q</name>
+    <load>0.52734</load>
+    <used>40</used>
+    <resv>4</resv>
+    <available>12</available>
+    <total>64</total>
+    <temp_disabled>0</temp_disabled>
+    <manual_intervention>8</manual_intervention>
+    <suspend_manual>0</suspend_manual>
+    <suspend_threshold>0</suspend_threshold>
+    <suspend_on_subordinate>0</suspend_on_subordinate>
+    <suspend_calendar>0</suspend_calendar>
+    <unknown>8</unknown>
+    <load_alarm>0</load_alarm>
+    <disabled_manual>0</disabled_manual>
+    <disabled_calendar>0</disabled_calendar>
+    <ambiguous>0</ambiguous>
+    <orphaned>0</orphaned>
+    <error>0</error>
+  </cluster_queue_summary>
+  <cluster_queue_summary>
+    <name>interactive.q</name>
+    <load>-NA-</load>
+    <used>0</used>
+    <resv>0</resv>
+    <available>0</available>
+    <total>16</total>
+    <temp_disabled>8</temp_disabled>
+    <manual_intervention>8</manual_intervention>
+    <suspend_manual>8</suspend_manual>
+    <suspend_threshold>0</suspend_threshold>
+    <suspend_on_subordinate>8</suspend_on_subordinate>
+    <suspend_calendar>0</suspend_calendar>
+    <unknown>0</unknown>
+    <load_alarm>0</load_alarm>
+    <disabled_manual>0</disabled_manual>
+    <disabled_calendar>0</disabled_calendar>
+    <ambiguous>0</ambiguous>
+    <orphaned>0</orphaned>
+    <error>0</error>
+  </cluster_queue_summary>
+</job_info>
+`
+
+func TestGetClusterQueueSummary(t *testing.T) {
+	r := &fakeRunner{output: []byte(clusterQueueSummary)}
+	defer useRunner(r)()
+	qs, err := GetClusterQueueSummary()
+	if err != nil {
+		t.Fatalf("GetClusterQueueSummary failed: %s", err)
+	}
+	args := []string{"-xml", "-g", "c", "-ext"}
+	if cmd := r.commands[0]; cmd.name != "qstat" || !reflect.DeepEqual(cmd.args, args) {
+		t.Errorf("ran %s %q, expected qstat %q", cmd.name, cmd.args, args)
+	}
+	expected := []ClusterQueueSummary{
+		{Name: "all.q", LoadAvg: 0.52734, Used: 40, Reserved: 4, Available: 12, Total: 64, Manual: 8},
+		{Name: "interactive.q", LoadAvg: -1, Total: 16, TempDisabled: 8, Manual: 8, Suspended: 16},
+	}
+	if !reflect.DeepEqual(qs, expected) {
+		t.Errorf("got %+v, expected %+v", qs, expected)
+	}
+}
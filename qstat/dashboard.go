@@ -0,0 +1,188 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kisielk/gorge/arco"
+)
+
+// DashboardFailureWindow is how far back a Dashboard looks for failed jobs
+const DashboardFailureWindow = 24 * time.Hour
+
+// DashboardTopUsers is the number of users listed in a Dashboard's TopUsers
+const DashboardTopUsers = 10
+
+// UserSlots is the number of slots occupied by a user's running jobs
+type UserSlots struct {
+	User  string `json:"user"`
+	Slots int    `json:"slots"`
+}
+
+// JobsSection summarizes the jobs in the queue
+type JobsSection struct {
+	Running  int         `json:"running"`  // Number of running job entries
+	Pending  int         `json:"pending"`  // Number of pending job entries
+	TopUsers []UserSlots `json:"topUsers"` // The users occupying the most slots, in descending order
+	Err      error       `json:"-"`        // The error fetching the section, if any
+}
+
+// CapacitySection is the slot usage of the cluster
+type CapacitySection struct {
+	Queues    []ClusterQueueSummary `json:"queues"`    // The usage of each cluster queue
+	Used      int                   `json:"used"`      // Slots used in all queues
+	Reserved  int                   `json:"reserved"`  // Slots reserved in all queues
+	Available int                   `json:"available"` // Slots available in all queues
+	Total     int                   `json:"total"`     // Total slots in all queues
+	Err       error                 `json:"-"`         // The error fetching the section, if any
+}
+
+// QueuesSection is the state of every queue instance
+type QueuesSection struct {
+	Queues []Queue `json:"queues"`
+	Err    error   `json:"-"` // The error fetching the section, if any
+}
+
+// FailuresSection lists jobs which recently finished with a non-zero exit status
+type FailuresSection struct {
+	Jobs []arco.Accounting `json:"jobs"`
+	Err  error             `json:"-"` // The error fetching the section, if any
+}
+
+// Dashboard is a snapshot of the cluster's state for display on a status page.
+// Each section is fetched independently; a section that could not be fetched has its Err set.
+type Dashboard struct {
+	Time     time.Time        `json:"time"`               // The time the snapshot was taken
+	Jobs     JobsSection      `json:"jobs"`               // Summary of running and pending jobs
+	Capacity CapacitySection  `json:"capacity"`           // Slot usage of the cluster queues
+	Queues   QueuesSection    `json:"queues"`             // State of the queue instances
+	Failures *FailuresSection `json:"failures,omitempty"` // Recent failures, nil if no ARCo database was given
+}
+
+// failedJobsSource provides the accounting records of jobs which failed in a time period, such as an arco.DB
+type failedJobsSource interface {
+	QueryFailedJobs(start, end time.Time) ([]arco.Accounting, error)
+}
+
+// Snapshot concurrently gathers the state of the cluster in to a Dashboard. If arcoDB is not nil,
+// jobs that failed within the last DashboardFailureWindow are included. A section that could not be
+// fetched does not prevent the others from being returned; an error is returned only if no section
+// could be fetched.
+func (c *Client) Snapshot(ctx context.Context, arcoDB *arco.DB) (*Dashboard, error) {
+	var failures failedJobsSource
+	if arcoDB != nil {
+		failures = arcoDB
+	}
+	return c.snapshot(ctx, failures, time.Now())
+}
+
+func (c *Client) snapshot(ctx context.Context, failures failedJobsSource, now time.Time) (*Dashboard, error) {
+	d := &Dashboard{Time: now}
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.Jobs = c.jobsSection(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.Capacity = c.capacitySection(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.Queues = c.queuesSection(ctx)
+	}()
+
+	if failures != nil {
+		d.Failures = new(FailuresSection)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			*d.Failures = failuresSection(failures, now.Add(-DashboardFailureWindow), now)
+		}()
+	}
+
+	wg.Wait()
+
+	if d.Jobs.Err != nil && d.Capacity.Err != nil && d.Queues.Err != nil && (d.Failures == nil || d.Failures.Err != nil) {
+		return d, fmt.Errorf("qstat: could not fetch dashboard: %s", d.Jobs.Err)
+	}
+	return d, nil
+}
+
+// jobsSection summarizes the queue for all users
+func (c *Client) jobsSection(ctx context.Context) JobsSection {
+	q, err := c.queueInfo(ctx, "*")
+	if err != nil {
+		return JobsSection{Err: err}
+	}
+	return summarizeJobs(q)
+}
+
+// capacitySection sums the slot usage of the cluster queues
+func (c *Client) capacitySection(ctx context.Context) CapacitySection {
+	qs, err := c.GetClusterQueueSummary(ctx)
+	if err != nil {
+		return CapacitySection{Err: err}
+	}
+	s := CapacitySection{Queues: qs}
+	for _, q := range qs {
+		s.Used += q.Used
+		s.Reserved += q.Reserved
+		s.Available += q.Available
+		s.Total += q.Total
+	}
+	return s
+}
+
+// queuesSection lists the queue instances with their states
+func (c *Client) queuesSection(ctx context.Context) QueuesSection {
+	qs, err := c.GetFullQueueInfo(ctx)
+	if err != nil {
+		return QueuesSection{Err: err}
+	}
+	return QueuesSection{Queues: qs}
+}
+
+// summarizeJobs counts the jobs in q and ranks users by the slots of their running jobs
+func summarizeJobs(q *QueueInfo) JobsSection {
+	s := JobsSection{Running: len(q.QueuedJobs), Pending: len(q.PendingJobs)}
+	slots := make(map[string]int)
+	for _, j := range q.QueuedJobs {
+		slots[j.Owner] += j.Slots
+	}
+	for u, n := range slots {
+		s.TopUsers = append(s.TopUsers, UserSlots{u, n})
+	}
+	sort.Slice(s.TopUsers, func(i, j int) bool {
+		if s.TopUsers[i].Slots != s.TopUsers[j].Slots {
+			return s.TopUsers[i].Slots > s.TopUsers[j].Slots
+		}
+		return s.TopUsers[i].User < s.TopUsers[j].User
+	})
+	if len(s.TopUsers) > DashboardTopUsers {
+		s.TopUsers = s.TopUsers[:DashboardTopUsers]
+	}
+	return s
+}
+
+// failuresSection returns the jobs in src that finished between start and end with a non-zero exit status
+func failuresSection(src failedJobsSource, start, end time.Time) FailuresSection {
+	as, err := src.QueryFailedJobs(start, end)
+	if err != nil {
+		return FailuresSection{Err: err}
+	}
+	return FailuresSection{Jobs: as}
+}
@@ -0,0 +1,139 @@
+package qstat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kisielk/gorge/arco"
+)
+
+// fakeAccounting is a failedJobsSource returning the failed jobs among canned records
+type fakeAccounting struct {
+	records []arco.Accounting
+	err     error
+}
+
+func (f fakeAccounting) QueryFailedJobs(start, end time.Time) ([]arco.Accounting, error) {
+	var failed []arco.Accounting
+	for _, a := range f.records {
+		if a.Failed() {
+			failed = append(failed, a)
+		}
+	}
+	return failed, f.err
+}
+
+// dashboardRunner is a Runner for the qstat commands of a snapshot, which returns the output and error
+// of the section whose option, -g c for capacity or -f for queues, is among a command's arguments.
+// The job list is keyed by an empty string. It may be used concurrently.
+type dashboardRunner struct {
+	outputs map[string][]byte
+	errs    map[string]error
+}
+
+func (r dashboardRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	key := ""
+	for _, a := range args {
+		if a == "-g" || a == "-f" {
+			key = a
+		}
+	}
+	return fakeOutput{bytes.NewReader(r.outputs[key]), "", r.errs[key]}, nil
+}
+
+// dashboardOutputs are the outputs of the qstat commands of a snapshot
+var dashboardOutputs = map[string][]byte{
+	"":   []byte(queueInfo),
+	"-g": []byte(clusterQueueSummary),
+	"-f": []byte(fullQueueInfo),
+}
+
+func TestSnapshot(t *testing.T) {
+	c := &Client{Runner: dashboardRunner{outputs: dashboardOutputs}}
+	failures := fakeAccounting{records: []arco.Accounting{
+		{JobNumber: 1, ExitStatus: 0},
+		{JobNumber: 2, ExitStatus: 137},
+	}}
+	now := time.Date(2012, 11, 1, 14, 0, 0, 0, time.UTC)
+
+	d, err := c.snapshot(context.Background(), failures, now)
+	if err != nil {
+		t.Fatalf("snapshot failed: %s", err)
+	}
+	expectedJobs := JobsSection{Running: 1, Pending: 1, TopUsers: []UserSlots{{"bob", 1}}}
+	if !reflect.DeepEqual(d.Jobs, expectedJobs) {
+		t.Errorf("Jobs got %+v, expected %+v", d.Jobs, expectedJobs)
+	}
+	if cp := d.Capacity; cp.Err != nil || len(cp.Queues) != 2 || cp.Used != 40 || cp.Reserved != 4 || cp.Available != 12 || cp.Total != 80 {
+		t.Errorf("unexpected capacity section: %+v", cp)
+	}
+	if qs := d.Queues; qs.Err != nil || len(qs.Queues) != 2 || qs.Queues[1].Name != "all.q@node02" || qs.Queues[1].State != "au" {
+		t.Errorf("unexpected queues section: %+v", qs)
+	}
+	if d.Failures == nil || d.Failures.Err != nil || len(d.Failures.Jobs) != 1 || d.Failures.Jobs[0].JobNumber != 2 {
+		t.Errorf("unexpected failures section: %+v", d.Failures)
+	}
+}
+
+func TestSnapshotPartialFailure(t *testing.T) {
+	qstatErr := errors.New("qstat: could not contact qmaster")
+	capacityErr := errors.New("qstat: -g c failed")
+	c := &Client{Runner: dashboardRunner{
+		outputs: dashboardOutputs,
+		errs:    map[string]error{"": qstatErr, "-g": capacityErr},
+	}}
+	failures := fakeAccounting{records: []arco.Accounting{{JobNumber: 2, ExitStatus: 1}}}
+
+	d, err := c.snapshot(context.Background(), failures, time.Now())
+	if err != nil {
+		t.Fatalf("snapshot failed: %s", err)
+	}
+	if d.Jobs.Err != qstatErr {
+		t.Errorf("Jobs error got %v, expected %v", d.Jobs.Err, qstatErr)
+	}
+	if d.Capacity.Err != capacityErr {
+		t.Errorf("Capacity error got %v, expected %v", d.Capacity.Err, capacityErr)
+	}
+	if d.Queues.Err != nil || len(d.Queues.Queues) != 2 {
+		t.Errorf("unexpected queues section: %+v", d.Queues)
+	}
+	if d.Failures == nil || d.Failures.Err != nil || len(d.Failures.Jobs) != 1 {
+		t.Errorf("unexpected failures section: %+v", d.Failures)
+	}
+
+	// The queues section alone is enough for a dashboard
+	if _, err := c.snapshot(context.Background(), nil, time.Now()); err != nil {
+		t.Errorf("snapshot failed with only the queues section: %s", err)
+	}
+
+	// With no other section to show, the error is returned
+	c.Runner = dashboardRunner{errs: map[string]error{"": qstatErr, "-g": capacityErr, "-f": qstatErr}}
+	if _, err := c.snapshot(context.Background(), nil, time.Now()); err == nil {
+		t.Errorf("expected error when every section fails")
+	}
+}
+
+func TestSummarizeJobs(t *testing.T) {
+	q := &QueueInfo{
+		QueuedJobs: []QueueJob{
+			{Owner: "bob", Slots: 4},
+			{Owner: "john", Slots: 8},
+			{Owner: "bob", Slots: 2},
+			{Owner: "alice", Slots: 6},
+		},
+		PendingJobs: []QueueJob{{Owner: "alice", Slots: 16}},
+	}
+	expected := JobsSection{
+		Running:  4,
+		Pending:  1,
+		TopUsers: []UserSlots{{"john", 8}, {"alice", 6}, {"bob", 6}},
+	}
+	if s := summarizeJobs(q); !reflect.DeepEqual(s, expected) {
+		t.Errorf("got %+v, expected %+v", s, expected)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"unicode"
+
+	"github.com/kisielk/gorge/util"
+)
+
+// Format is the encoding of qstat output
+type Format int
+
+const (
+	FormatAuto Format = iota // Detect the format from the first non-whitespace character
+	FormatXML                // The output of qstat -xml
+	FormatJSON               // The JSON encoding of the types in this package
+)
+
+// sniffFormat peeks at the first non-whitespace byte of r to tell XML from JSON
+func sniffFormat(r *bufio.Reader) (Format, error) {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if len(b) < n {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return FormatAuto, err
+		}
+		switch c := b[n-1]; {
+		case c == '<':
+			return FormatXML, nil
+		case c == '{' || c == '[':
+			return FormatJSON, nil
+		case !unicode.IsSpace(rune(c)):
+			return FormatAuto, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+}
+
+// DecodeQstat decodes qstat output read from r in to result.
+// XML is decoded leniently and with invalid UTF-8 removed, as qstat produces both from time to time.
+// If format is FormatAuto the format is detected from the output.
+func DecodeQstat(r io.Reader, format Format, result interface{}) error {
+	if format == FormatAuto {
+		br := bufio.NewReader(r)
+		var err error
+		if format, err = sniffFormat(br); err != nil {
+			return fmt.Errorf("qstat: could not detect output format: %s", err)
+		}
+		r = br
+	}
+
+	var err error
+	switch format {
+	case FormatXML:
+		dec := xml.NewDecoder(util.NewValidUTF8Reader(r))
+		dec.Strict = false
+		err = dec.Decode(result)
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(result)
+	default:
+		return fmt.Errorf("qstat: unknown output format %d", format)
+	}
+	if err != nil {
+		return fmt.Errorf("qstat: could not decode output: %s", err)
+	}
+	return nil
+}
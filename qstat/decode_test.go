@@ -0,0 +1,56 @@
+package qstat
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeQstat(t *testing.T) {
+	var fromXML QueueInfo
+	if err := DecodeQstat(strings.NewReader(queueInfo), FormatAuto, &fromXML); err != nil {
+		t.Fatalf("could not decode XML: %s", err)
+	}
+	if len(fromXML.QueuedJobs) != 1 || len(fromXML.PendingJobs) != 1 {
+		t.Fatalf("unexpected queue info: %+v", fromXML)
+	}
+
+	b, err := json.MarshalIndent(fromXML, "", "  ")
+	if err != nil {
+		t.Fatalf("could not encode JSON: %s", err)
+	}
+	queueInfoJSON := "\n\t " + string(b)
+
+	tests := []struct {
+		name   string
+		input  string
+		format Format
+	}{
+		{"xml", queueInfo, FormatXML},
+		{"sniffed xml", "\n  " + queueInfo, FormatAuto},
+		{"json", queueInfoJSON, FormatJSON},
+		{"sniffed json", queueInfoJSON, FormatAuto},
+	}
+	for _, test := range tests {
+		var q QueueInfo
+		if err := DecodeQstat(strings.NewReader(test.input), test.format, &q); err != nil {
+			t.Errorf("%s: DecodeQstat failed: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(q, fromXML) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, q, fromXML)
+		}
+	}
+
+	for _, input := range []string{"", "   ", "qstat: command not found"} {
+		var q QueueInfo
+		if err := DecodeQstat(strings.NewReader(input), FormatAuto, &q); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+	var q QueueInfo
+	if err := DecodeQstat(strings.NewReader(queueInfo), Format(42), &q); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+// Filter returns the jobs in js for which keep returns true, in their original order.
+// js is not modified.
+func Filter(js []QueueJob, keep func(QueueJob) bool) []QueueJob {
+	var kept []QueueJob
+	for _, j := range js {
+		if keep(j) {
+			kept = append(kept, j)
+		}
+	}
+	return kept
+}
+
+// FilterRunning returns the jobs in js in the (r)unning state
+func FilterRunning(js []QueueJob) []QueueJob {
+	return Filter(js, QueueJob.RunningState)
+}
+
+// FilterPending returns the jobs in js that are (q)ueued waiting to be scheduled, including held jobs
+func FilterPending(js []QueueJob) []QueueJob {
+	return Filter(js, QueueJob.QueuedState)
+}
+
+// FilterError returns the jobs in js in the (E)rror state
+func FilterError(js []QueueJob) []QueueJob {
+	return Filter(js, QueueJob.ErrorState)
+}
@@ -0,0 +1,43 @@
+package qstat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	jobs := []QueueJob{
+		{JobNumber: 1, State: "r"},
+		{JobNumber: 2, State: "qw"},
+		{JobNumber: 3, State: "Eqw"},
+		{JobNumber: 4, State: "hqw"},
+		{JobNumber: 5, State: "dr"},
+		{JobNumber: 6, State: "t"},
+		{JobNumber: 7, State: "s"},
+	}
+	tests := []struct {
+		name     string
+		filter   func([]QueueJob) []QueueJob
+		expected []int
+	}{
+		{"running", FilterRunning, []int{1, 5}},
+		{"pending", FilterPending, []int{2, 3, 4}},
+		{"error", FilterError, []int{3}},
+	}
+	for _, test := range tests {
+		if ns := jobNumbers(test.filter(jobs)); !reflect.DeepEqual(ns, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, ns, test.expected)
+		}
+	}
+
+	held := Filter(jobs, func(j QueueJob) bool { return j.HoldState() })
+	if ns := jobNumbers(held); !reflect.DeepEqual(ns, []int{4}) {
+		t.Errorf("held: got %v, expected [4]", ns)
+	}
+	if js := Filter(jobs, func(QueueJob) bool { return false }); js != nil {
+		t.Errorf("got %v, expected no jobs", js)
+	}
+	if jobs[0].JobNumber != 1 || len(jobs) != 7 {
+		t.Errorf("Filter modified its input: %v", jobNumbers(jobs))
+	}
+}
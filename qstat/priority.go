@@ -0,0 +1,61 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"sort"
+)
+
+// SchedulingPolicy holds the weights the scheduler uses to combine the normalized priority
+// components of a job in to its overall priority.
+// See man 5 sched_conf for a description of the weights
+type SchedulingPolicy struct {
+	WeightPriority float64 `json:"weightPriority"` // Weight of the normalized POSIX priority (weight_priority)
+	WeightUrgency  float64 `json:"weightUrgency"`  // Weight of the normalized urgency (weight_urgency)
+	WeightTicket   float64 `json:"weightTicket"`   // Weight of the normalized tickets (weight_ticket)
+}
+
+// DefaultSchedulingPolicy has the weights of a default scheduler configuration
+var DefaultSchedulingPolicy = SchedulingPolicy{
+	WeightPriority: 1,
+	WeightUrgency:  0.1,
+	WeightTicket:   0.01,
+}
+
+// EffectivePriority returns the priority of the job under the policy, computed from its
+// normalized POSIX priority, urgency and tickets.
+func (j QueueJob) EffectivePriority(policy SchedulingPolicy) float64 {
+	return policy.WeightPriority*j.NormalizedPriorityPosix +
+		policy.WeightUrgency*j.NormalizedUrgency +
+		policy.WeightTicket*j.NormalizedTickets
+}
+
+// sortJobs returns a copy of js sorted in descending order of the priority returned by prio.
+// Jobs with equal priority keep their relative order.
+func sortJobs(js []QueueJob, prio func(QueueJob) float64) []QueueJob {
+	sorted := make([]QueueJob, len(js))
+	copy(sorted, js)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return prio(sorted[i]) > prio(sorted[j])
+	})
+	return sorted
+}
+
+// PendingByPriority returns the pending jobs in the order of the priority reported by the scheduler,
+// highest first.
+func (q *QueueInfo) PendingByPriority() []QueueJob {
+	return sortJobs(q.PendingJobs, func(j QueueJob) float64 {
+		return j.NormalizedPriority
+	})
+}
+
+// RankPending returns the pending jobs ordered by their effective priority under policy,
+// highest first. It can be used to preview the effect of changing the scheduler's weights.
+// The normalized components are those computed by the scheduler under its current configuration.
+func (q *QueueInfo) RankPending(policy SchedulingPolicy) []QueueJob {
+	return sortJobs(q.PendingJobs, func(j QueueJob) float64 {
+		return j.EffectivePriority(policy)
+	})
+}
@@ -0,0 +1,54 @@
+package qstat
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func jobNumbers(js []QueueJob) []int {
+	var ns []int
+	for _, j := range js {
+		ns = append(ns, j.JobNumber)
+	}
+	return ns
+}
+
+var pendingJobs = &QueueInfo{PendingJobs: []QueueJob{
+	{JobNumber: 1, NormalizedPriority: 0.5, NormalizedPriorityPosix: 0.5, NormalizedUrgency: 0.1, NormalizedTickets: 0.9},
+	{JobNumber: 2, NormalizedPriority: 0.7, NormalizedPriorityPosix: 0.5, NormalizedUrgency: 0.9, NormalizedTickets: 0.1},
+	{JobNumber: 3, NormalizedPriority: 0.6, NormalizedPriorityPosix: 0.6, NormalizedUrgency: 0.0, NormalizedTickets: 0.0},
+}}
+
+func TestEffectivePriority(t *testing.T) {
+	j := pendingJobs.PendingJobs[0]
+	if p, expected := j.EffectivePriority(DefaultSchedulingPolicy), 0.5+0.01+0.009; math.Abs(p-expected) > 1e-9 {
+		t.Errorf("got %f, expected %f", p, expected)
+	}
+}
+
+func TestPendingByPriority(t *testing.T) {
+	if got, expected := jobNumbers(pendingJobs.PendingByPriority()), []int{2, 3, 1}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestRankPending(t *testing.T) {
+	tests := []struct {
+		policy   SchedulingPolicy
+		expected []int
+	}{
+		{DefaultSchedulingPolicy, []int{3, 2, 1}},
+		{SchedulingPolicy{WeightUrgency: 1}, []int{2, 1, 3}},
+		{SchedulingPolicy{WeightTicket: 1}, []int{1, 2, 3}},
+		{SchedulingPolicy{}, []int{1, 2, 3}},
+	}
+	for i, test := range tests {
+		if got := jobNumbers(pendingJobs.RankPending(test.policy)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%d: got %v, expected %v", i, got, test.expected)
+		}
+	}
+	if got := jobNumbers(pendingJobs.PendingJobs); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("pending jobs were reordered: %v", got)
+	}
+}
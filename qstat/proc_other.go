@@ -0,0 +1,14 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package qstat
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on systems without process groups, cancelling cmd only kills the command itself.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,299 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccountingRecord represents the accounting information of a finished job or task as reported by qacct -j.
+// The fields mirror those of arco.Accounting where they overlap.
+// See man 5 accounting for a more detailed description of the fields
+type AccountingRecord struct {
+	JobNumber      int       `json:"jobNumber"`      // The job number
+	TaskNumber     int       `json:"taskNumber"`     // The array task number, 0 for non-array jobs
+	Name           string    `json:"jobName"`        // The job name
+	Group          string    `json:"group"`          // The group of the job owner
+	Username       string    `json:"userName"`       // The job owner
+	Account        string    `json:"account"`        // The account string of the job
+	Project        string    `json:"project"`        // The project of the job
+	Department     string    `json:"department"`     // The department of the job owner
+	QueueName      string    `json:"queueName"`      // The name of the cluster queue the job ran in
+	Hostname       string    `json:"hostname"`       // The host the job ran on
+	SubmissionTime time.Time `json:"submissionTime"` // The time the job was submitted
+	StartTime      time.Time `json:"startTime"`      // The time the job started
+	EndTime        time.Time `json:"endTime"`        // The time the job ended
+	GrantedPE      string    `json:"grantedPe"`      // The parallel environment granted to the job
+	Slots          int       `json:"slots"`          // The number of slots granted to the job
+	ARParent       int       `json:"arParent"`       // The advance reservation the job ran in, 0 if none
+	Failed         string    `json:"failed"`         // The failure code and reason, "0" if the job did not fail
+	ExitStatus     int       `json:"exitStatus"`     // The exit status of the job script
+	WallClockTime  int       `json:"wallClockTime"`  // The wall clock time in seconds
+	CPU            float64   `json:"cpu"`            // The CPU time in seconds
+	Memory         float64   `json:"memory"`         // The integral memory usage in GB * CPU seconds
+	IO             float64   `json:"io"`             // The amount of data transferred in GB
+	IOWait         float64   `json:"ioWait"`         // The IO wait time in seconds
+	MaxVMem        float64   `json:"maxVmem"`        // The maximum virtual memory in bytes
+	MaxRSS         int       `json:"maxRss"`         // The maximum resident set size in kilobytes
+}
+
+// qacctTimeLayouts are the layouts qacct uses for times, which are in local time
+var qacctTimeLayouts = []string{
+	"Mon Jan _2 15:04:05 2006",
+	"01/02/2006 15:04:05.000",
+	"01/02/2006 15:04:05",
+}
+
+func parseQacctTime(s string) (time.Time, error) {
+	if s == "" || s == "-/-" {
+		return time.Time{}, nil
+	}
+	for _, layout := range qacctTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time (%s)", s)
+}
+
+// parseQacctNumber parses a number which may have a unit suffix. Times may be suffixed with s,
+// and memory values with one of the multipliers K, M, G or T (powers of 1024), optionally followed
+// by B, and s for integral memory usage. When the suffix includes B the value is returned in gigabytes,
+// the unit qacct uses for mem and io, otherwise in base units.
+func parseQacctNumber(s string) (float64, error) {
+	s = strings.TrimSuffix(s, "s")
+	gigabytes := strings.HasSuffix(s, "B")
+	s = strings.TrimSuffix(s, "B")
+	multiplier := 1.0
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K', 'k':
+			multiplier, s = 1<<10, s[:n-1]
+		case 'M', 'm':
+			multiplier, s = 1<<20, s[:n-1]
+		case 'G', 'g':
+			multiplier, s = 1<<30, s[:n-1]
+		case 'T', 't':
+			multiplier, s = 1<<40, s[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number (%s)", s)
+	}
+	if gigabytes {
+		return f * multiplier / (1 << 30), nil
+	}
+	return f * multiplier, nil
+}
+
+// parseQacctInt parses an integer value, treating "undefined" as 0
+func parseQacctInt(s string) (int, error) {
+	if s == "undefined" || s == "NONE" {
+		return 0, nil
+	}
+	f, err := parseQacctNumber(s)
+	return int(f), err
+}
+
+// newAccountingRecord converts the attributes of one record of qacct -j output to an AccountingRecord
+func newAccountingRecord(attrs map[string]string) (AccountingRecord, error) {
+	a := AccountingRecord{
+		Name:       attrs["jobname"],
+		Group:      attrs["group"],
+		Username:   attrs["owner"],
+		Account:    attrs["account"],
+		Project:    attrs["project"],
+		Department: attrs["department"],
+		QueueName:  attrs["qname"],
+		Hostname:   attrs["hostname"],
+		GrantedPE:  attrs["granted_pe"],
+		Failed:     attrs["failed"],
+	}
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"jobnumber", &a.JobNumber},
+		{"taskid", &a.TaskNumber},
+		{"slots", &a.Slots},
+		{"arid", &a.ARParent},
+		{"exit_status", &a.ExitStatus},
+		{"ru_wallclock", &a.WallClockTime},
+		{"ru_maxrss", &a.MaxRSS},
+	}
+	for _, f := range ints {
+		if v, ok := attrs[f.key]; ok {
+			n, err := parseQacctInt(v)
+			if err != nil {
+				return a, fmt.Errorf("%s: %s", f.key, err)
+			}
+			*f.dst = n
+		}
+	}
+	floats := []struct {
+		key string
+		dst *float64
+	}{
+		{"cpu", &a.CPU},
+		{"mem", &a.Memory},
+		{"io", &a.IO},
+		{"iow", &a.IOWait},
+		{"maxvmem", &a.MaxVMem},
+	}
+	for _, f := range floats {
+		if v, ok := attrs[f.key]; ok {
+			n, err := parseQacctNumber(v)
+			if err != nil {
+				return a, fmt.Errorf("%s: %s", f.key, err)
+			}
+			*f.dst = n
+		}
+	}
+	times := []struct {
+		key string
+		dst *time.Time
+	}{
+		{"qsub_time", &a.SubmissionTime},
+		{"start_time", &a.StartTime},
+		{"end_time", &a.EndTime},
+	}
+	for _, f := range times {
+		t, err := parseQacctTime(attrs[f.key])
+		if err != nil {
+			return a, fmt.Errorf("%s: %s", f.key, err)
+		}
+		*f.dst = t
+	}
+	return a, nil
+}
+
+// parseAccountingRecords parses the output of qacct -j, which consists of records separated by a
+// line of = characters, each containing one attribute name and value per line.
+func parseAccountingRecords(r io.Reader) ([]AccountingRecord, error) {
+	var records []AccountingRecord
+	var attrs map[string]string
+	flush := func() error {
+		if attrs == nil {
+			return nil
+		}
+		a, err := newAccountingRecord(attrs)
+		if err != nil {
+			return fmt.Errorf("qacct: could not parse record %d: %s", len(records)+1, err)
+		}
+		records = append(records, a)
+		attrs = nil
+		return nil
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "====") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		key := strings.Fields(line)[0]
+		attrs[key] = strings.TrimSpace(line[len(key):])
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// AcctFilter restricts the records returned by GetAccountingFiltered. Zero valued fields do not filter.
+type AcctFilter struct {
+	Begin      time.Time // Only jobs started at or after Begin (qacct -b)
+	End        time.Time // Only jobs started at or before End (qacct -e)
+	Owner      string    // Only jobs of the owner (qacct -o)
+	Host       string    // Only jobs that ran on the host (qacct -h)
+	Queue      string    // Only jobs that ran in the queue (qacct -q)
+	Project    string    // Only jobs of the project (qacct -P)
+	Department string    // Only jobs of the department (qacct -D)
+	Days       int       // Only jobs started in the last Days days (qacct -d)
+	JobNumber  int       // Only the tasks of the job (qacct -j job_id)
+}
+
+// qacctTimeFormat is the [[CC]YY]MMDDhhmm[.SS] format qacct accepts for -b and -e
+const qacctTimeFormat = "200601021504.05"
+
+// args returns the qacct arguments selecting the records matching f
+func (f AcctFilter) args() []string {
+	var args []string
+	if !f.Begin.IsZero() {
+		args = append(args, "-b", f.Begin.Local().Format(qacctTimeFormat))
+	}
+	if !f.End.IsZero() {
+		args = append(args, "-e", f.End.Local().Format(qacctTimeFormat))
+	}
+	for _, opt := range []struct{ flag, value string }{
+		{"-o", f.Owner},
+		{"-h", f.Host},
+		{"-q", f.Queue},
+		{"-P", f.Project},
+		{"-D", f.Department},
+	} {
+		if opt.value != "" {
+			args = append(args, opt.flag, opt.value)
+		}
+	}
+	if f.Days > 0 {
+		args = append(args, "-d", strconv.Itoa(f.Days))
+	}
+	if f.JobNumber > 0 {
+		return append(args, "-j", strconv.Itoa(f.JobNumber))
+	}
+	return append(args, "-j")
+}
+
+// GetAccountingFiltered returns the accounting records of all jobs matching filter using qacct.
+func (c *Client) GetAccountingFiltered(filter AcctFilter) ([]AccountingRecord, error) {
+	out, err := c.output(context.Background(), "qacct", filter.args()...)
+	if err != nil {
+		return nil, err
+	}
+	return parseAccountingRecords(bytes.NewReader(out))
+}
+
+// GetJobAccounting returns the accounting records of the tasks of a finished job using qacct -j.
+// If qacct has no record of the job the error matches ErrUnknownJob with errors.Is.
+func (c *Client) GetJobAccounting(jobNumber int) ([]AccountingRecord, error) {
+	as, err := c.GetAccountingFiltered(AcctFilter{JobNumber: jobNumber})
+	var qerr *QstatError
+	if errors.As(err, &qerr) && strings.Contains(qerr.Stderr, "not found") {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownJob, jobNumber)
+	}
+	return as, err
+}
+
+// GetJobAccounting returns the accounting records of the tasks of a finished job using qacct -j.
+func GetJobAccounting(jobNumber int) ([]AccountingRecord, error) {
+	return DefaultClient.GetJobAccounting(jobNumber)
+}
+
+// GetAccountingFiltered returns the accounting records of all jobs matching filter using qacct.
+func GetAccountingFiltered(filter AcctFilter) ([]AccountingRecord, error) {
+	return DefaultClient.GetAccountingFiltered(filter)
+}
@@ -0,0 +1,182 @@
+package qstat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const qacctRecords = `==============================================================
+qname        all.q               
+hostname     node01.cluster      
+group        users               
+owner        bob                 
+project      some_project        
+department   defaultdepartment   
+jobname      render.sh           
+jobnumber    3064080             
+taskid       undefined           
+account      sge                 
+priority     0                   
+qsub_time    Thu Nov  1 12:00:00 2012
+start_time   Thu Nov  1 12:01:00 2012
+end_time     Thu Nov  1 13:00:00 2012
+granted_pe   NONE                
+slots        1                   
+failed       0    
+exit_status  0                   
+ru_wallclock 3540         
+ru_utime     2990.120     
+ru_stime     10.380       
+ru_maxrss    524288              
+cpu          3000.500     
+mem          120.250           
+io           1.500             
+iow          0.500             
+maxvmem      1.000G
+arid         undefined
+==============================================================
+qname        gpu.q               
+hostname     node20.cluster      
+group        users               
+owner        john                
+project      NONE                
+department   defaultdepartment   
+jobname      train               
+jobnumber    3064090             
+taskid       7                   
+account      sge                 
+priority     0                   
+qsub_time    11/01/2012 12:30:00.120
+start_time   11/01/2012 12:31:00.000
+end_time     11/01/2012 12:41:00.500
+granted_pe   smp                 
+slots        8                   
+failed       100 : assumedly after job
+exit_status  137                 
+ru_wallclock 600s
+ru_maxrss    2097152             
+cpu          4700.000s
+mem          900.000GBs
+io           0.250GB
+iow          0.000s
+maxvmem      16.000G
+arid         42
+`
+
+func TestParseAccountingRecords(t *testing.T) {
+	as, err := parseAccountingRecords(strings.NewReader(qacctRecords))
+	if err != nil {
+		t.Fatalf("parse failed: %s", err)
+	}
+	if len(as) != 2 {
+		t.Fatalf("got %d records, expected 2", len(as))
+	}
+
+	expected := AccountingRecord{
+		JobNumber:      3064080,
+		Name:           "render.sh",
+		Group:          "users",
+		Username:       "bob",
+		Account:        "sge",
+		Project:        "some_project",
+		Department:     "defaultdepartment",
+		QueueName:      "all.q",
+		Hostname:       "node01.cluster",
+		SubmissionTime: time.Date(2012, 11, 1, 12, 0, 0, 0, time.Local),
+		StartTime:      time.Date(2012, 11, 1, 12, 1, 0, 0, time.Local),
+		EndTime:        time.Date(2012, 11, 1, 13, 0, 0, 0, time.Local),
+		GrantedPE:      "NONE",
+		Slots:          1,
+		Failed:         "0",
+		WallClockTime:  3540,
+		CPU:            3000.5,
+		Memory:         120.25,
+		IO:             1.5,
+		IOWait:         0.5,
+		MaxVMem:        1 << 30,
+		MaxRSS:         524288,
+	}
+	if !reflect.DeepEqual(as[0], expected) {
+		t.Errorf("got %+v, expected %+v", as[0], expected)
+	}
+
+	a := as[1]
+	if a.JobNumber != 3064090 || a.TaskNumber != 7 || a.Username != "john" || a.QueueName != "gpu.q" || a.Hostname != "node20.cluster" {
+		t.Errorf("unexpected identification of second record: %+v", a)
+	}
+	if a.ExitStatus != 137 || a.Failed != "100 : assumedly after job" || a.ARParent != 42 || a.Slots != 8 {
+		t.Errorf("unexpected status of second record: %+v", a)
+	}
+	if a.WallClockTime != 600 || a.CPU != 4700 || a.Memory != 900 || a.IO != 0.25 || a.MaxVMem != 16<<30 {
+		t.Errorf("unexpected usage of second record: %+v", a)
+	}
+	if end := time.Date(2012, 11, 1, 12, 41, 0, 500e6, time.Local); !a.EndTime.Equal(end) {
+		t.Errorf("got end time %s, expected %s", a.EndTime, end)
+	}
+}
+
+func TestParseAccountingRecordsInvalid(t *testing.T) {
+	in := "==========\njobnumber 12\nslots many\n"
+	if _, err := parseAccountingRecords(strings.NewReader(in)); err == nil {
+		t.Errorf("expected error for invalid slots")
+	}
+	as, err := parseAccountingRecords(strings.NewReader(""))
+	if err != nil || len(as) != 0 {
+		t.Errorf("got %v, %v for empty output, expected no records", as, err)
+	}
+}
+
+func TestGetAccountingFiltered(t *testing.T) {
+	r := &fakeRunner{output: []byte(qacctRecords)}
+	c := &Client{Runner: r}
+	filter := AcctFilter{
+		Begin: time.Date(2012, 11, 1, 0, 0, 0, 0, time.Local),
+		End:   time.Date(2012, 11, 2, 0, 0, 30, 0, time.Local),
+		Owner: "bob",
+		Queue: "all.q",
+	}
+	as, err := c.GetAccountingFiltered(filter)
+	if err != nil {
+		t.Fatalf("GetAccountingFiltered failed: %s", err)
+	}
+	if len(as) != 2 {
+		t.Errorf("got %d records, expected 2", len(as))
+	}
+	expected := []fakeCommand{{"qacct", []string{"-b", "201211010000.00", "-e", "201211020000.30", "-o", "bob", "-q", "all.q", "-j"}}}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Errorf("got commands %v, expected %v", r.commands, expected)
+	}
+}
+
+func TestGetJobAccounting(t *testing.T) {
+	r := &fakeRunner{output: []byte(qacctRecords)}
+	c := &Client{Runner: r}
+	if _, err := c.GetJobAccounting(3064080); err != nil {
+		t.Fatalf("GetJobAccounting failed: %s", err)
+	}
+	if args := r.commands[0].args; !reflect.DeepEqual(args, []string{"-j", "3064080"}) {
+		t.Errorf("ran qacct %q, expected qacct -j 3064080", args)
+	}
+
+	r.output = nil
+	r.err = &QstatError{ExitCode: 1, Stderr: "error: job id 3064080 not found", Args: []string{"qacct"}}
+	if _, err := c.GetJobAccounting(3064080); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("got error %v, expected ErrUnknownJob", err)
+	}
+	r.err = &QstatError{ExitCode: 1, Stderr: "error: can't open accounting file", Args: []string{"qacct"}}
+	if _, err := c.GetJobAccounting(3064080); err == nil || errors.Is(err, ErrUnknownJob) {
+		t.Errorf("got error %v, expected a failure other than ErrUnknownJob", err)
+	}
+
+	r.err = nil
+	r.commands = nil
+	if _, err := c.GetAccountingFiltered(AcctFilter{Owner: "bob", Days: 7}); err != nil {
+		t.Fatalf("GetAccountingFiltered failed: %s", err)
+	}
+	if args := r.commands[0].args; !reflect.DeepEqual(args, []string{"-o", "bob", "-d", "7", "-j"}) {
+		t.Errorf("ran qacct %q, expected qacct -o bob -d 7 -j", args)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// qconf runs qconf with the given arguments using DefaultClient and returns its output
+func qconf(args ...string) ([]byte, error) {
+	out, err := DefaultClient.output(context.Background(), "qconf", args...)
+	if err != nil {
+		return nil, fmt.Errorf("qconf: could not run qconf %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// ComplexDefinition represents the definition of a complex resource attribute as configured with qconf -mc.
+// See man 5 complex for a more detailed description of the fields
+type ComplexDefinition struct {
+	Name        string  `json:"name"`        // The name of the complex
+	Shortcut    string  `json:"shortcut"`    // A shortcut that can be used instead of the name in resource requests
+	Type        string  `json:"type"`        // The value type, eg: INT, DOUBLE, MEMORY, TIME, STRING, BOOL
+	RelOp       string  `json:"relOp"`       // The relation operator used to compare requests to values
+	Requestable string  `json:"requestable"` // YES, NO or FORCED
+	Consumable  string  `json:"consumable"`  // YES, NO, JOB or HOST
+	Default     string  `json:"default"`     // The default request for consumables
+	Urgency     float64 `json:"urgency"`     // The urgency contributed by requesting the resource
+}
+
+// parseComplexDefinitions parses the output of qconf -sc in to a map of definitions keyed by complex name.
+func parseComplexDefinitions(r io.Reader) (map[string]ComplexDefinition, error) {
+	defs := make(map[string]ComplexDefinition)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		// Newer versions append further columns, such as aapre, which are ignored.
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("qconf: could not parse complex: too few fields in %q", line)
+		}
+		urgency, err := strconv.ParseFloat(fields[7], 64)
+		if err != nil {
+			return nil, fmt.Errorf("qconf: could not parse complex %s: invalid urgency (%s)", fields[0], fields[7])
+		}
+		defs[fields[0]] = ComplexDefinition{
+			Name:        fields[0],
+			Shortcut:    fields[1],
+			Type:        fields[2],
+			RelOp:       fields[3],
+			Requestable: fields[4],
+			Consumable:  fields[5],
+			Default:     fields[6],
+			Urgency:     urgency,
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+var complexCache struct {
+	sync.Mutex
+	defs map[string]ComplexDefinition
+}
+
+// GetComplexDefinitions returns the cluster's complex definitions as reported by qconf -sc, keyed by name.
+// The definitions rarely change, so they are cached after the first successful call. A failed call is
+// not cached, so calling again retries qconf. Each call returns a new map, which the caller may modify.
+func GetComplexDefinitions() (map[string]ComplexDefinition, error) {
+	complexCache.Lock()
+	defer complexCache.Unlock()
+	if complexCache.defs == nil {
+		defs, err := readComplexDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		complexCache.defs = defs
+	}
+	defs := make(map[string]ComplexDefinition, len(complexCache.defs))
+	for name, d := range complexCache.defs {
+		defs[name] = d
+	}
+	return defs, nil
+}
+
+// readComplexDefinitions runs qconf -sc and parses its output
+func readComplexDefinitions() (map[string]ComplexDefinition, error) {
+	out, err := qconf("-sc")
+	if err != nil {
+		return nil, err
+	}
+	return parseComplexDefinitions(bytes.NewReader(out))
+}
+
+// Definition looks up the complex definition of the resource in defs by name or shortcut.
+func (r Resource) Definition(defs map[string]ComplexDefinition) (ComplexDefinition, bool) {
+	if d, ok := defs[r.Name]; ok {
+		return d, true
+	}
+	for _, d := range defs {
+		if d.Shortcut == r.Name {
+			return d, true
+		}
+	}
+	return ComplexDefinition{}, false
+}
+
+// NormalizeResourceNames returns a copy of rs with each resource name replaced by the full name
+// of its complex in defs, so that resources requested by shortcut compare equal to those requested
+// by name. Resources without a definition keep their name.
+func NormalizeResourceNames(rs []Resource, defs map[string]ComplexDefinition) []Resource {
+	normalized := make([]Resource, 0, len(rs))
+	for _, r := range rs {
+		if d, ok := r.Definition(defs); ok {
+			r.Name = d.Name
+		}
+		normalized = append(normalized, r)
+	}
+	return normalized
+}
+
+// parseConfig parses the key/value format used by qconf to show configuration objects.
+// Each line holds an attribute name followed by its value. Long values are continued on
+// the following line when a line ends with a backslash.
+func parseConfig(r io.Reader) (map[string]string, error) {
+	config := make(map[string]string)
+	s := bufio.NewScanner(r)
+	var line string
+	for s.Scan() {
+		text := strings.TrimSpace(s.Text())
+		if strings.HasSuffix(text, "\\") {
+			line += strings.TrimSpace(strings.TrimSuffix(text, "\\")) + " "
+			continue
+		}
+		line += text
+		if line != "" && !strings.HasPrefix(line, "#") {
+			key := strings.Fields(line)[0]
+			config[key] = strings.TrimSpace(line[len(key):])
+		}
+		line = ""
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if line != "" {
+		return nil, fmt.Errorf("qconf: could not parse configuration: unterminated continuation line")
+	}
+	return config, nil
+}
+
+// configList splits a list-valued configuration attribute. The value NONE is an empty list.
+func configList(v string) []string {
+	if v == "" || v == "NONE" {
+		return nil
+	}
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// configValue returns the attribute value, or the empty string if it is NONE.
+func configValue(v string) string {
+	if v == "NONE" {
+		return ""
+	}
+	return v
+}
+
+// configInt parses an integer valued attribute named key in config.
+func configInt(config map[string]string, key string) (int, error) {
+	n, err := strconv.Atoi(config[key])
+	if err != nil {
+		return 0, fmt.Errorf("qconf: could not parse %s: invalid value (%s)", key, config[key])
+	}
+	return n, nil
+}
+
+// configBool parses a TRUE or FALSE valued attribute.
+func configBool(v string) bool {
+	return strings.EqualFold(v, "TRUE")
+}
+
+// qconfList runs qconf with arguments that list object names, one per line.
+func qconfList(args ...string) ([]string, error) {
+	out, err := qconf(args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// ParallelEnvironment represents the configuration of a parallel environment.
+// See man 5 sge_pe for a more detailed description of the fields
+type ParallelEnvironment struct {
+	Name              string   `json:"name"`              // The name of the parallel environment
+	Slots             int      `json:"slots"`             // The number of slots that may be used concurrently by all jobs in the PE
+	UserLists         []string `json:"userLists"`         // Access lists allowed to use the PE
+	XUserLists        []string `json:"xuserLists"`        // Access lists denied from using the PE
+	StartProcArgs     string   `json:"startProcArgs"`     // The command run before starting a job, empty if none
+	StopProcArgs      string   `json:"stopProcArgs"`      // The command run after a job finishes, empty if none
+	AllocationRule    string   `json:"allocationRule"`    // A fixed number of slots per host, $pe_slots, $fill_up or $round_robin
+	ControlSlaves     bool     `json:"controlSlaves"`     // True if slave tasks are started with qrsh -inherit
+	JobIsFirstTask    bool     `json:"jobIsFirstTask"`    // True if the job script is the first task of the parallel job
+	UrgencySlots      string   `json:"urgencySlots"`      // How slots are counted for urgency: min, max, avg or a number
+	AccountingSummary bool     `json:"accountingSummary"` // True if a single accounting record is written for the job
+}
+
+// parseParallelEnvironment parses the output of qconf -sp.
+func parseParallelEnvironment(r io.Reader) (*ParallelEnvironment, error) {
+	config, err := parseConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := configInt(config, "slots")
+	if err != nil {
+		return nil, err
+	}
+	return &ParallelEnvironment{
+		Name:              config["pe_name"],
+		Slots:             slots,
+		UserLists:         configList(config["user_lists"]),
+		XUserLists:        configList(config["xuser_lists"]),
+		StartProcArgs:     configValue(config["start_proc_args"]),
+		StopProcArgs:      configValue(config["stop_proc_args"]),
+		AllocationRule:    config["allocation_rule"],
+		ControlSlaves:     configBool(config["control_slaves"]),
+		JobIsFirstTask:    configBool(config["job_is_first_task"]),
+		UrgencySlots:      config["urgency_slots"],
+		AccountingSummary: configBool(config["accounting_summary"]),
+	}, nil
+}
+
+// GetParallelEnvironments returns the names of all configured parallel environments, as listed by qconf -spl.
+func GetParallelEnvironments() ([]string, error) {
+	return qconfList("-spl")
+}
+
+// GetParallelEnvironment returns the configuration of the named parallel environment, as shown by qconf -sp.
+func GetParallelEnvironment(name string) (*ParallelEnvironment, error) {
+	out, err := qconf("-sp", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseParallelEnvironment(bytes.NewReader(out))
+}
+
+// splitQueueValue splits a cluster queue attribute value in to its default value and the
+// host or host group specific values given in the form "default,[host=value],...".
+func splitQueueValue(v string) (def string, hosts map[string]string) {
+	var defs []string
+	var elems []string
+	depth, start := 0, 0
+	for i, c := range v {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			elems = append(elems, v[start:i])
+			start = i + 1
+		}
+	}
+	elems = append(elems, v[start:])
+
+	for _, elem := range elems {
+		elem = strings.TrimSpace(elem)
+		if strings.HasPrefix(elem, "[") && strings.HasSuffix(elem, "]") {
+			kv := strings.SplitN(elem[1:len(elem)-1], "=", 2)
+			if len(kv) == 2 {
+				if hosts == nil {
+					hosts = make(map[string]string)
+				}
+				hosts[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+				continue
+			}
+		}
+		if elem != "" {
+			defs = append(defs, elem)
+		}
+	}
+	return strings.Join(defs, ","), hosts
+}
+
+// configMap splits a list of name=value pairs, such as complex_values, in to a map.
+func configMap(v string) map[string]string {
+	list := configList(v)
+	if list == nil {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, elem := range list {
+		kv := strings.SplitN(elem, "=", 2)
+		if len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		} else {
+			m[kv[0]] = ""
+		}
+	}
+	return m
+}
+
+// QueueConfig represents the configuration of a cluster queue.
+// See man 5 queue_conf for a more detailed description of the fields.
+// The typed fields hold the default values of the cluster queue; use Value to
+// resolve an attribute for a specific host.
+type QueueConfig struct {
+	Name              string            `json:"name"`              // The name of the cluster queue
+	HostList          []string          `json:"hostList"`          // The hosts and host groups the queue runs on
+	SeqNo             int               `json:"seqNo"`             // The sequence number used when sorting queues
+	LoadThresholds    map[string]string `json:"loadThresholds"`    // Load values which put the queue in alarm state
+	SuspendThresholds map[string]string `json:"suspendThresholds"` // Load values which suspend jobs in the queue
+	QType             []string          `json:"qType"`             // The queue types, eg: BATCH, INTERACTIVE
+	PEList            []string          `json:"peList"`            // Parallel environments available in the queue
+	Slots             int               `json:"slots"`             // The number of slots per queue instance
+	UserLists         []string          `json:"userLists"`         // Access lists allowed to use the queue
+	XUserLists        []string          `json:"xuserLists"`        // Access lists denied from using the queue
+	Projects          []string          `json:"projects"`          // Projects allowed to use the queue
+	XProjects         []string          `json:"xprojects"`         // Projects denied from using the queue
+	ComplexValues     map[string]string `json:"complexValues"`     // Resource capacities of the queue
+	Calendar          string            `json:"calendar"`          // The calendar attached to the queue, empty if none
+	Attributes        map[string]string `json:"attributes"`        // All attributes as reported by qconf, including host specific values
+}
+
+// Value returns the value of the attribute attr for a queue instance on host, taking in to account
+// any host specific value configured for it.
+func (c QueueConfig) Value(attr, host string) string {
+	def, hosts := splitQueueValue(c.Attributes[attr])
+	if v, ok := hosts[host]; ok {
+		return v
+	}
+	return def
+}
+
+// parseQueueConfig parses the output of qconf -sq.
+func parseQueueConfig(r io.Reader) (*QueueConfig, error) {
+	config, err := parseConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	def := func(key string) string {
+		d, _ := splitQueueValue(config[key])
+		return d
+	}
+	c := &QueueConfig{
+		Name:              config["qname"],
+		HostList:          configList(def("hostlist")),
+		LoadThresholds:    configMap(def("load_thresholds")),
+		SuspendThresholds: configMap(def("suspend_thresholds")),
+		QType:             configList(def("qtype")),
+		PEList:            configList(def("pe_list")),
+		UserLists:         configList(def("user_lists")),
+		XUserLists:        configList(def("xuser_lists")),
+		Projects:          configList(def("projects")),
+		XProjects:         configList(def("xprojects")),
+		ComplexValues:     configMap(def("complex_values")),
+		Calendar:          configValue(def("calendar")),
+		Attributes:        config,
+	}
+	if c.SeqNo, err = strconv.Atoi(def("seq_no")); err != nil {
+		return nil, fmt.Errorf("qconf: could not parse seq_no: invalid value (%s)", config["seq_no"])
+	}
+	if c.Slots, err = strconv.Atoi(def("slots")); err != nil {
+		return nil, fmt.Errorf("qconf: could not parse slots: invalid value (%s)", config["slots"])
+	}
+	return c, nil
+}
+
+// GetQueueNames returns the names of all cluster queues, as listed by qconf -sql.
+func GetQueueNames() ([]string, error) {
+	return qconfList("-sql")
+}
+
+// GetQueueConfig returns the configuration of the named cluster queue, as shown by qconf -sq.
+func GetQueueConfig(name string) (*QueueConfig, error) {
+	out, err := qconf("-sq", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseQueueConfig(bytes.NewReader(out))
+}
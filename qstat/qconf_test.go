@@ -0,0 +1,259 @@
+package qstat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const complexDefinitions = `#name               shortcut   type        relop requestable consumable default  urgency 
+#----------------------------------------------------------------------------------------
+arch                a          RESTRING    ==    YES         NO         NONE     0
+calendar            c          RESTRING    ==    YES         NO         NONE     0
+h_rt                h_rt       TIME        <=    YES         NO         0:0:0    0
+h_vmem              h_vmem     MEMORY      <=    YES         YES        2G       0
+mem_free            mf         MEMORY      <=    YES         NO         0        0
+slots               s          INT         <=    YES         YES        1        1000
+# >#< starts a comment but comments are not saved across edits --------
+`
+
+func TestParseComplexDefinitions(t *testing.T) {
+	defs, err := parseComplexDefinitions(strings.NewReader(complexDefinitions))
+	if err != nil {
+		t.Fatalf("parse failed: %s", err)
+	}
+	if len(defs) != 6 {
+		t.Errorf("got %d definitions, expected 6", len(defs))
+	}
+	expected := map[string]ComplexDefinition{
+		"mem_free": {"mem_free", "mf", "MEMORY", "<=", "YES", "NO", "0", 0},
+		"slots":    {"slots", "s", "INT", "<=", "YES", "YES", "1", 1000},
+		"h_rt":     {"h_rt", "h_rt", "TIME", "<=", "YES", "NO", "0:0:0", 0},
+	}
+	for name, e := range expected {
+		if d := defs[name]; !reflect.DeepEqual(d, e) {
+			t.Errorf("%s: got %v, expected %v", name, d, e)
+		}
+	}
+
+	if _, err := parseComplexDefinitions(strings.NewReader("slots s INT <= YES\n")); err == nil {
+		t.Errorf("expected error for truncated line")
+	}
+	if _, err := parseComplexDefinitions(strings.NewReader("slots s INT <= YES YES 1 high\n")); err == nil {
+		t.Errorf("expected error for invalid urgency")
+	}
+}
+
+func TestGetComplexDefinitions(t *testing.T) {
+	complexCache.defs = nil
+	defer func() { complexCache.defs = nil }()
+	r := &fakeRunner{output: []byte(complexDefinitions)}
+	defer useRunner(r)()
+
+	defs, err := GetComplexDefinitions()
+	if err != nil {
+		t.Fatalf("GetComplexDefinitions failed: %s", err)
+	}
+	delete(defs, "slots")
+	defs["arch"] = ComplexDefinition{Name: "changed"}
+
+	again, err := GetComplexDefinitions()
+	if err != nil {
+		t.Fatalf("GetComplexDefinitions failed: %s", err)
+	}
+	if len(r.commands) != 1 {
+		t.Errorf("ran qconf %d times, expected the definitions to be cached", len(r.commands))
+	}
+	if _, ok := again["slots"]; !ok || again["arch"].Name != "arch" || len(again) != 6 {
+		t.Errorf("changes to the returned map affected the cache: %+v", again)
+	}
+}
+
+func TestResourceDefinition(t *testing.T) {
+	defs, err := parseComplexDefinitions(strings.NewReader(complexDefinitions))
+	if err != nil {
+		t.Fatalf("parse failed: %s", err)
+	}
+	tests := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"mem_free", "mem_free", true},
+		{"mf", "mem_free", true},
+		{"h_rt", "h_rt", true},
+		{"gpu", "", false},
+	}
+	for i, test := range tests {
+		d, ok := Resource{Name: test.name}.Definition(defs)
+		if ok != test.ok || d.Name != test.expected {
+			t.Errorf("%d: got %q, %v, expected %q, %v", i, d.Name, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestParseParallelEnvironment(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected ParallelEnvironment
+	}{
+		{`pe_name            smp
+slots              9999
+user_lists         NONE
+xuser_lists        NONE
+start_proc_args    /bin/true
+stop_proc_args     /bin/true
+allocation_rule    $pe_slots
+control_slaves     FALSE
+job_is_first_task  TRUE
+urgency_slots      min
+accounting_summary FALSE
+`, ParallelEnvironment{
+			Name:           "smp",
+			Slots:          9999,
+			StartProcArgs:  "/bin/true",
+			StopProcArgs:   "/bin/true",
+			AllocationRule: "$pe_slots",
+			JobIsFirstTask: true,
+			UrgencySlots:   "min",
+		}},
+		{`pe_name            mpi
+slots              512
+user_lists         mpi_users,admins
+xuser_lists        NONE
+start_proc_args    $SGE_ROOT/mpi/startmpi.sh -catch_rsh \
+                   $pe_hostfile
+stop_proc_args     $SGE_ROOT/mpi/stopmpi.sh
+allocation_rule    $round_robin
+control_slaves     TRUE
+job_is_first_task  FALSE
+urgency_slots      min
+accounting_summary TRUE
+`, ParallelEnvironment{
+			Name:              "mpi",
+			Slots:             512,
+			UserLists:         []string{"mpi_users", "admins"},
+			StartProcArgs:     "$SGE_ROOT/mpi/startmpi.sh -catch_rsh $pe_hostfile",
+			StopProcArgs:      "$SGE_ROOT/mpi/stopmpi.sh",
+			AllocationRule:    "$round_robin",
+			ControlSlaves:     true,
+			UrgencySlots:      "min",
+			AccountingSummary: true,
+		}},
+	}
+
+	for i, test := range tests {
+		pe, err := parseParallelEnvironment(strings.NewReader(test.in))
+		if err != nil {
+			t.Errorf("%d: parse failed: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(*pe, test.expected) {
+			t.Errorf("%d: got %+v, expected %+v", i, *pe, test.expected)
+		}
+	}
+
+	if _, err := parseParallelEnvironment(strings.NewReader("pe_name smp\nslots many\n")); err == nil {
+		t.Errorf("expected error for invalid slots")
+	}
+}
+
+const queueConfig = `qname                 all.q
+hostlist              @allhosts node20
+seq_no                0
+load_thresholds       np_load_avg=1.75
+suspend_thresholds    NONE
+nsuspend              1
+suspend_interval      00:05:00
+priority              0
+min_cpu_interval      00:05:00
+processors            UNDEFINED
+qtype                 BATCH INTERACTIVE
+ckpt_list             NONE
+pe_list               make smp,[node20=make smp mpi]
+rerun                 FALSE
+slots                 1,[node01=8],[node02=16]
+tmpdir                /tmp
+shell                 /bin/sh
+prolog                NONE
+epilog                NONE
+shell_start_mode      posix_compliant
+user_lists            staff
+xuser_lists           NONE
+subordinate_list      NONE
+complex_values        h_vmem=4G,gpu=0,[node20=h_vmem=64G,gpu=4]
+projects              NONE
+xprojects             NONE
+calendar              NONE
+initial_state         default
+s_rt                  INFINITY
+h_rt                  48:00:00
+`
+
+func TestParseQueueConfig(t *testing.T) {
+	c, err := parseQueueConfig(strings.NewReader(queueConfig))
+	if err != nil {
+		t.Fatalf("parse failed: %s", err)
+	}
+	attrs := c.Attributes
+	c.Attributes = nil
+	expected := QueueConfig{
+		Name:           "all.q",
+		HostList:       []string{"@allhosts", "node20"},
+		LoadThresholds: map[string]string{"np_load_avg": "1.75"},
+		QType:          []string{"BATCH", "INTERACTIVE"},
+		PEList:         []string{"make", "smp"},
+		Slots:          1,
+		UserLists:      []string{"staff"},
+		ComplexValues:  map[string]string{"h_vmem": "4G", "gpu": "0"},
+	}
+	if !reflect.DeepEqual(*c, expected) {
+		t.Errorf("got %+v, expected %+v", *c, expected)
+	}
+	if attrs["h_rt"] != "48:00:00" {
+		t.Errorf("h_rt attribute got %q, expected %q", attrs["h_rt"], "48:00:00")
+	}
+
+	c.Attributes = attrs
+	tests := []struct {
+		attr, host, expected string
+	}{
+		{"slots", "node01", "8"},
+		{"slots", "node02", "16"},
+		{"slots", "node03", "1"},
+		{"pe_list", "node20", "make smp mpi"},
+		{"complex_values", "node20", "h_vmem=64G,gpu=4"},
+		{"complex_values", "node01", "h_vmem=4G,gpu=0"},
+		{"shell", "node01", "/bin/sh"},
+	}
+	for i, test := range tests {
+		if v := c.Value(test.attr, test.host); v != test.expected {
+			t.Errorf("%d: %s on %s got %q, expected %q", i, test.attr, test.host, v, test.expected)
+		}
+	}
+}
+
+func TestNormalizeResourceNames(t *testing.T) {
+	defs, err := parseComplexDefinitions(strings.NewReader(complexDefinitions))
+	if err != nil {
+		t.Fatalf("parse failed: %s", err)
+	}
+	rs := []Resource{
+		{Name: "mf", StringVal: "4G"},
+		{Name: "h_rt", StringVal: "3600"},
+		{Name: "a", StringVal: "lx-amd64"},
+		{Name: "gpu", StringVal: "1"},
+	}
+	expected := []Resource{
+		{Name: "mem_free", StringVal: "4G"},
+		{Name: "h_rt", StringVal: "3600"},
+		{Name: "arch", StringVal: "lx-amd64"},
+		{Name: "gpu", StringVal: "1"},
+	}
+	if got := NormalizeResourceNames(rs, defs); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+	if rs[0].Name != "mf" {
+		t.Errorf("input was modified: %v", rs)
+	}
+}
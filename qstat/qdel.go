@@ -0,0 +1,82 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// QdelArrayTasks deletes the tasks in the range of the array job jobNumber.
+func (c *Client) QdelArrayTasks(jobNumber int, tasks TaskIDRange) error {
+	return c.QdelArrayTaskRanges(jobNumber, []TaskIDRange{tasks})
+}
+
+// QdelArrayTaskRanges deletes the tasks in several ranges of the array job jobNumber.
+func (c *Client) QdelArrayTaskRanges(jobNumber int, tasks []TaskIDRange) error {
+	if len(tasks) == 0 {
+		return fmt.Errorf("qdel: no task ranges given for job %d", jobNumber)
+	}
+	var args []string
+	for _, r := range tasks {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("qdel: %s", err)
+		}
+		args = append(args, strconv.Itoa(jobNumber), "-t", r.String())
+	}
+	_, err := c.output(context.Background(), "qdel", args...)
+	return err
+}
+
+// QdelError is returned when qdel fails to delete some of the jobs it is given
+type QdelError = JobError
+
+// Qdel deletes the jobs with the given job numbers, including all of the tasks of array jobs.
+// If qdel reports that some of the jobs could not be deleted, such as because they don't exist or
+// belong to another user, the error is a *QdelError giving the reason for each.
+func (c *Client) Qdel(jobNumbers ...int) error {
+	if len(jobNumbers) == 0 {
+		return fmt.Errorf("qdel: no jobs given")
+	}
+	var args []string
+	for _, n := range jobNumbers {
+		args = append(args, strconv.Itoa(n))
+	}
+	return c.qdel(jobNumbers, args...)
+}
+
+// QdelTasks deletes the tasks of the array job jobNumber given by a range expression as accepted by NewTaskIDRange, eg: 1-10:2.
+// Failures are reported as for Qdel.
+func (c *Client) QdelTasks(jobNumber int, tasks string) error {
+	r, err := NewTaskIDRange(tasks)
+	if err == nil {
+		err = r.validate()
+	}
+	if err != nil {
+		return fmt.Errorf("qdel: %s", err)
+	}
+	return c.qdel([]int{jobNumber}, strconv.Itoa(jobNumber), "-t", r.String())
+}
+
+// Qdel deletes the jobs with the given job numbers using DefaultClient
+func Qdel(jobNumbers ...int) error {
+	return DefaultClient.Qdel(jobNumbers...)
+}
+
+// QdelTasks deletes the tasks in the range of the array job jobNumber using DefaultClient
+func QdelTasks(jobNumber int, tasks string) error {
+	return DefaultClient.QdelTasks(jobNumber, tasks)
+}
+
+// qdelDeleted matches the messages qdel prints for a job it has deleted or is deleting
+var qdelDeleted = regexp.MustCompile(`has (registered the job|deleted)|already in deletion`)
+
+// qdel runs qdel with the given arguments, which delete the jobs in jobNumbers
+func (c *Client) qdel(jobNumbers []int, args ...string) error {
+	out, err := c.output(context.Background(), "qdel", args...)
+	return jobErrors("qdel", "could not delete", jobNumbers, qdelDeleted, out, err)
+}
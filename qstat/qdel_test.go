@@ -0,0 +1,120 @@
+package qstat
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQdelArrayTasks(t *testing.T) {
+	r := new(fakeRunner)
+	c := &Client{Runner: r}
+	if err := c.QdelArrayTasks(3064076, TaskIDRange{2, 8, 2}); err != nil {
+		t.Fatalf("QdelArrayTasks failed: %s", err)
+	}
+	expected := []fakeCommand{{"qdel", []string{"3064076", "-t", "2-8:2"}}}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Errorf("got commands %v, expected %v", r.commands, expected)
+	}
+
+	r = new(fakeRunner)
+	c = &Client{Runner: r}
+	if err := c.QdelArrayTaskRanges(42, []TaskIDRange{{1, 1, 1}, {5, 9, 1}}); err != nil {
+		t.Fatalf("QdelArrayTaskRanges failed: %s", err)
+	}
+	expected = []fakeCommand{{"qdel", []string{"42", "-t", "1", "42", "-t", "5-9"}}}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Errorf("got commands %v, expected %v", r.commands, expected)
+	}
+}
+
+func TestQdelArrayTasksInvalid(t *testing.T) {
+	r := new(fakeRunner)
+	c := &Client{Runner: r}
+	for i, tr := range []TaskIDRange{{0, 4, 1}, {4, 2, 1}, {1, 4, 0}} {
+		if err := c.QdelArrayTasks(42, tr); err == nil {
+			t.Errorf("%d: expected error for %v", i, tr)
+		}
+	}
+	if err := c.QdelArrayTaskRanges(42, nil); err == nil {
+		t.Errorf("expected error for no ranges")
+	}
+	if len(r.commands) != 0 {
+		t.Errorf("expected no commands to run, got %v", r.commands)
+	}
+}
+
+func TestQdelArrayTasksFailure(t *testing.T) {
+	r := &fakeRunner{err: io.ErrUnexpectedEOF}
+	c := &Client{Runner: r}
+	if err := c.QdelArrayTasks(42, TaskIDRange{1, 2, 1}); err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestQdel(t *testing.T) {
+	r := &fakeRunner{output: []byte("bob has registered the job 101 for deletion\nbob has deleted job 102\n")}
+	c := &Client{Runner: r}
+	if err := c.Qdel(101, 102); err != nil {
+		t.Fatalf("Qdel failed: %s", err)
+	}
+	if err := c.QdelTasks(103, "1-10:3"); err != nil {
+		t.Fatalf("QdelTasks failed: %s", err)
+	}
+	expected := []fakeCommand{
+		{"qdel", []string{"101", "102"}},
+		{"qdel", []string{"103", "-t", "1-10:3"}},
+	}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Errorf("got commands %v, expected %v", r.commands, expected)
+	}
+
+	if err := c.Qdel(); err == nil {
+		t.Errorf("expected error for no jobs")
+	}
+	for _, tasks := range []string{"1-10:0", "bogus", "0-4"} {
+		if err := c.QdelTasks(103, tasks); err == nil {
+			t.Errorf("%q: expected error", tasks)
+		}
+	}
+	if len(r.commands) != 2 {
+		t.Errorf("invalid requests ran commands: %v", r.commands[2:])
+	}
+}
+
+func TestQdelFailure(t *testing.T) {
+	qerr := &QstatError{ExitCode: 1, Stderr: `denied: job "1203" does not exist`, Args: []string{"qdel"}}
+	r := &fakeRunner{
+		output: []byte("bob has registered the job 101 for deletion\njob 102 is already in deletion\n" +
+			"john - you do not have the necessary privileges to delete the job \"1001\"\n"),
+		err: qerr,
+	}
+	c := &Client{Runner: r}
+	err := c.Qdel(101, 102, 1001, 1203)
+	var derr *QdelError
+	if !errors.As(err, &derr) {
+		t.Fatalf("got error %v, expected a *QdelError", err)
+	}
+	expected := map[int]string{
+		1001: `john - you do not have the necessary privileges to delete the job "1001"`,
+		1203: `denied: job "1203" does not exist`,
+	}
+	if !reflect.DeepEqual(derr.Jobs, expected) {
+		t.Errorf("got failures %v, expected %v", derr.Jobs, expected)
+	}
+	if !errors.Is(err, qerr) {
+		t.Errorf("QdelError does not wrap the qdel exit status")
+	}
+	if s := err.Error(); !strings.HasPrefix(s, "qdel: could not delete job 1001: ") || !strings.Contains(s, "; job 1203: denied") {
+		t.Errorf("got error string %q", s)
+	}
+
+	// A failure which doesn't name any of the jobs is returned as is
+	r.output = nil
+	r.err = &QstatError{ExitCode: 1, Stderr: "error: unable to contact qmaster", Args: []string{"qdel"}}
+	if err := c.Qdel(101); err != r.err {
+		t.Errorf("got error %v, expected %v", err, r.err)
+	}
+}
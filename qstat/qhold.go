@@ -0,0 +1,88 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// holdModified matches the message qhold and qrls print for a job whose hold they changed
+var holdModified = regexp.MustCompile(`modified hold of`)
+
+// hold runs qhold or qrls on the jobs in jobNumbers, each given as a job or job and task range argument
+func (c *Client) hold(name string, jobNumbers []int, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s: no jobs given", name)
+	}
+	out, err := c.output(context.Background(), name, args...)
+	return jobErrors(name, "", jobNumbers, holdModified, out, err)
+}
+
+// holdJobs runs qhold or qrls on whole jobs
+func (c *Client) holdJobs(name string, jobNumbers []int) error {
+	var args []string
+	for _, n := range jobNumbers {
+		args = append(args, strconv.Itoa(n))
+	}
+	return c.hold(name, jobNumbers, args...)
+}
+
+// holdTasks runs qhold or qrls on the tasks of an array job given by a range expression
+func (c *Client) holdTasks(name string, jobNumber int, tasks string) error {
+	r, err := NewTaskIDRange(tasks)
+	if err == nil {
+		err = r.validate()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return c.hold(name, []int{jobNumber}, fmt.Sprintf("%d.%s", jobNumber, r))
+}
+
+// Qhold places a user hold on the jobs with the given job numbers, so they won't be scheduled until released.
+// If qhold reports that it could not hold some of the jobs the error is a *JobError giving the reason for each.
+func (c *Client) Qhold(jobNumbers ...int) error {
+	return c.holdJobs("qhold", jobNumbers)
+}
+
+// QholdTasks places a user hold on the tasks of the array job jobNumber given by a range expression
+// as accepted by NewTaskIDRange, eg: 1-10:2.
+func (c *Client) QholdTasks(jobNumber int, tasks string) error {
+	return c.holdTasks("qhold", jobNumber, tasks)
+}
+
+// Qrls releases the user hold of the jobs with the given job numbers.
+// Failures are reported as for Qhold.
+func (c *Client) Qrls(jobNumbers ...int) error {
+	return c.holdJobs("qrls", jobNumbers)
+}
+
+// QrlsTasks releases the user hold of the tasks of the array job jobNumber given by a range expression.
+func (c *Client) QrlsTasks(jobNumber int, tasks string) error {
+	return c.holdTasks("qrls", jobNumber, tasks)
+}
+
+// Qhold places a user hold on jobs using DefaultClient
+func Qhold(jobNumbers ...int) error {
+	return DefaultClient.Qhold(jobNumbers...)
+}
+
+// QholdTasks places a user hold on the tasks of an array job using DefaultClient
+func QholdTasks(jobNumber int, tasks string) error {
+	return DefaultClient.QholdTasks(jobNumber, tasks)
+}
+
+// Qrls releases the user hold of jobs using DefaultClient
+func Qrls(jobNumbers ...int) error {
+	return DefaultClient.Qrls(jobNumbers...)
+}
+
+// QrlsTasks releases the user hold of the tasks of an array job using DefaultClient
+func QrlsTasks(jobNumber int, tasks string) error {
+	return DefaultClient.QrlsTasks(jobNumber, tasks)
+}
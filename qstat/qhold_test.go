@@ -0,0 +1,62 @@
+package qstat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQhold(t *testing.T) {
+	r := &fakeRunner{output: []byte("modified hold of job 101\nmodified hold of job 102\n")}
+	c := &Client{Runner: r}
+	if err := c.Qhold(101, 102); err != nil {
+		t.Fatalf("Qhold failed: %s", err)
+	}
+	if err := c.QholdTasks(103, "2-8:2"); err != nil {
+		t.Fatalf("QholdTasks failed: %s", err)
+	}
+	if err := c.Qrls(101); err != nil {
+		t.Fatalf("Qrls failed: %s", err)
+	}
+	if err := c.QrlsTasks(103, "4"); err != nil {
+		t.Fatalf("QrlsTasks failed: %s", err)
+	}
+	expected := []fakeCommand{
+		{"qhold", []string{"101", "102"}},
+		{"qhold", []string{"103.2-8:2"}},
+		{"qrls", []string{"101"}},
+		{"qrls", []string{"103.4"}},
+	}
+	if !reflect.DeepEqual(r.commands, expected) {
+		t.Errorf("got commands %v, expected %v", r.commands, expected)
+	}
+
+	if err := c.Qhold(); err == nil {
+		t.Errorf("expected error for no jobs")
+	}
+	if err := c.QrlsTasks(103, "1-10:0"); err == nil {
+		t.Errorf("expected error for an invalid task range")
+	}
+	if len(r.commands) != len(expected) {
+		t.Errorf("invalid requests ran commands: %v", r.commands[len(expected):])
+	}
+}
+
+func TestQholdFailure(t *testing.T) {
+	r := &fakeRunner{
+		output: []byte("modified hold of job 101\n"),
+		err:    &QstatError{ExitCode: 1, Stderr: `denied: job "999" does not exist`, Args: []string{"qhold"}},
+	}
+	c := &Client{Runner: r}
+	err := c.Qhold(101, 999)
+	var jerr *JobError
+	if !errors.As(err, &jerr) {
+		t.Fatalf("got error %v, expected a *JobError", err)
+	}
+	if expected := map[int]string{999: `denied: job "999" does not exist`}; jerr.Command != "qhold" || !reflect.DeepEqual(jerr.Jobs, expected) {
+		t.Errorf("got %s failures %v, expected %v", jerr.Command, jerr.Jobs, expected)
+	}
+	if s := err.Error(); s != `qhold: job 999: denied: job "999" does not exist` {
+		t.Errorf("got error string %q", s)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"strconv"
+)
+
+// Host is an execution host as reported by qhost.
+// Values which qhost reports as unknown, shown as -, are set to -1.
+type Host struct {
+	Name      string         `json:"name"`                // The host name
+	Arch      string         `json:"arch"`                // The architecture string, eg: lx26-amd64
+	NumProc   int            `json:"numProc"`             // The number of processors
+	LoadAvg   float64        `json:"loadAvg"`             // The load average
+	MemTotal  float64        `json:"memTotal"`            // The total memory in bytes
+	MemUsed   float64        `json:"memUsed"`             // The used memory in bytes
+	SwapTotal float64        `json:"swapTotal"`           // The total swap space in bytes
+	SwapUsed  float64        `json:"swapUsed"`            // The used swap space in bytes
+	Resources []HostResource `json:"resources,omitempty"` // The complex resource values of the host, reported by GetHostResources
+}
+
+// HostResource is the value of a complex resource on a host, as reported by qhost -F.
+// The first letter of the dominance is where the value comes from, the (g)lobal host, the (h)ost or
+// the (q)ueue, and the second whether it is a (l)oad value, a (c)onsumable's remaining capacity or a (f)ixed value.
+type HostResource struct {
+	Name      string `json:"name"`      // The name of the complex resource, eg: gpu
+	Value     string `json:"value"`     // The value, eg: 2.000000 or 14.5G
+	Dominance string `json:"dominance"` // The source and kind of the value, eg: hc
+}
+
+// Number returns the value of the resource as a number, with memory suffixes such as G expanded.
+func (r HostResource) Number() (float64, error) {
+	return parseQacctNumber(r.Value)
+}
+
+// Resource returns the value of the named complex resource on the host, and whether the host has it
+func (h Host) Resource(name string) (HostResource, bool) {
+	for _, r := range h.Resources {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return HostResource{}, false
+}
+
+// DefaultIdleLoadPerCore is the load per processor below which ClassifyHosts considers a host idle.
+const DefaultIdleLoadPerCore = 0.1
+
+// ClassifyHosts sorts hosts by their load average relative to their number of processors.
+// A host is overloaded if its load is above loadPerCore times its processor count,
+// and idle if it is below DefaultIdleLoadPerCore times its processor count.
+// Hosts with an unknown load or processor count, such as hosts which are down, are returned in unknown.
+func ClassifyHosts(hosts []Host, loadPerCore float64) (overloaded, idle, normal, unknown []Host) {
+	return ClassifyHostsIdle(hosts, loadPerCore, DefaultIdleLoadPerCore)
+}
+
+// ClassifyHostsIdle is like ClassifyHosts, but a host is idle if its load is below idleLoadPerCore
+// times its processor count.
+func ClassifyHostsIdle(hosts []Host, loadPerCore, idleLoadPerCore float64) (overloaded, idle, normal, unknown []Host) {
+	for _, h := range hosts {
+		switch ncpu := float64(h.NumProc); {
+		case h.LoadAvg < 0 || h.NumProc <= 0:
+			unknown = append(unknown, h)
+		case h.LoadAvg > ncpu*loadPerCore:
+			overloaded = append(overloaded, h)
+		case h.LoadAvg < ncpu*idleLoadPerCore:
+			idle = append(idle, h)
+		default:
+			normal = append(normal, h)
+		}
+	}
+	return
+}
+
+// hostXML is a host element of qhost -xml output
+type hostXML struct {
+	Name   string `xml:"name,attr"`
+	Values []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"hostvalue"`
+	Resources []struct {
+		Name      string `xml:"name,attr"`
+		Dominance string `xml:"dominance,attr"`
+		Value     string `xml:",chardata"`
+	} `xml:"resourcevalue"`
+}
+
+// host converts h to a Host
+func (h hostXML) host() Host {
+	host := Host{Name: h.Name, NumProc: -1, LoadAvg: -1, MemTotal: -1, MemUsed: -1, SwapTotal: -1, SwapUsed: -1}
+	memory := map[string]*float64{
+		"mem_total":  &host.MemTotal,
+		"mem_used":   &host.MemUsed,
+		"swap_total": &host.SwapTotal,
+		"swap_used":  &host.SwapUsed,
+	}
+	for _, v := range h.Values {
+		switch v.Name {
+		case "arch_string":
+			if v.Value != "-" {
+				host.Arch = v.Value
+			}
+		case "num_proc":
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				host.NumProc = n
+			}
+		case "load_avg":
+			if f, err := strconv.ParseFloat(v.Value, 64); err == nil {
+				host.LoadAvg = f
+			}
+		default:
+			// Memory values have a unit suffix, eg: 15.6G
+			if p, ok := memory[v.Name]; ok {
+				if f, err := parseQacctNumber(v.Value); err == nil {
+					*p = f
+				}
+			}
+		}
+	}
+	for _, r := range h.Resources {
+		host.Resources = append(host.Resources, HostResource{r.Name, r.Value, r.Dominance})
+	}
+	return host
+}
+
+// GetHosts returns the execution hosts and their load, by running qhost.
+// The global pseudo host qhost lists first is not included.
+func (c *Client) GetHosts(ctx context.Context) ([]Host, error) {
+	return c.hosts(ctx)
+}
+
+// GetHostResources is like GetHosts, but also returns the values of the complex resources of each host,
+// such as the number of free GPUs, by running qhost -F.
+func (c *Client) GetHostResources(ctx context.Context) ([]Host, error) {
+	return c.hosts(ctx, "-F")
+}
+
+// hosts runs qhost with the given arguments and returns the hosts it lists
+func (c *Client) hosts(ctx context.Context, args ...string) ([]Host, error) {
+	var result struct {
+		Hosts []hostXML `xml:"host"`
+	}
+	if _, err := c.xmlCommand(ctx, "qhost", &result, args...); err != nil {
+		return nil, err
+	}
+	var hosts []Host
+	for _, h := range result.Hosts {
+		if h.Name == "global" {
+			continue
+		}
+		hosts = append(hosts, h.host())
+	}
+	return hosts, nil
+}
+
+// GetHosts returns the execution hosts and their load using DefaultClient
+func GetHosts() ([]Host, error) {
+	return DefaultClient.GetHosts(context.Background())
+}
+
+// GetHostResources returns the execution hosts and their complex resource values using DefaultClient
+func GetHostResources() ([]Host, error) {
+	return DefaultClient.GetHostResources(context.Background())
+}
@@ -0,0 +1,172 @@
+package qstat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func hostNames(hosts []Host) []string {
+	var names []string
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+func TestClassifyHosts(t *testing.T) {
+	hosts := []Host{
+		{Name: "busy", NumProc: 8, LoadAvg: 12.5},
+		{Name: "quiet", NumProc: 8, LoadAvg: 0.2},
+		{Name: "working", NumProc: 8, LoadAvg: 7.9},
+		{Name: "down", NumProc: -1, LoadAvg: -1},
+		{Name: "noload", NumProc: 4, LoadAvg: -1},
+		{Name: "full", NumProc: 4, LoadAvg: 4},
+		{Name: "small", NumProc: 1, LoadAvg: 1.5},
+	}
+
+	overloaded, idle, normal, unknown := ClassifyHosts(hosts, 1)
+
+	tests := []struct {
+		name     string
+		got      []Host
+		expected []string
+	}{
+		{"overloaded", overloaded, []string{"busy", "small"}},
+		{"idle", idle, []string{"quiet"}},
+		{"normal", normal, []string{"working", "full"}},
+		{"unknown", unknown, []string{"down", "noload"}},
+	}
+	for _, test := range tests {
+		if names := hostNames(test.got); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, names, test.expected)
+		}
+	}
+
+	overloaded, _, normal, _ = ClassifyHosts(hosts, 1.5)
+	if names := hostNames(overloaded); !reflect.DeepEqual(names, []string{"busy"}) {
+		t.Errorf("threshold 1.5: got overloaded %v, expected [busy]", names)
+	}
+	if names := hostNames(normal); !reflect.DeepEqual(names, []string{"working", "full", "small"}) {
+		t.Errorf("threshold 1.5: got normal %v, expected [working full small]", names)
+	}
+
+	_, idle, normal, _ = ClassifyHostsIdle(hosts, 1, 0.01)
+	if names := hostNames(idle); len(names) != 0 {
+		t.Errorf("idle threshold 0.01: got idle %v, expected none", names)
+	}
+	if names := hostNames(normal); !reflect.DeepEqual(names, []string{"quiet", "working", "full"}) {
+		t.Errorf("idle threshold 0.01: got normal %v, expected [quiet working full]", names)
+	}
+}
+
+// hostInfo is qhost -xml output for a cluster with one host down
+const hostInfo = `<?xml version='1.0'?>
+<qhost xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qhost/qhost.xsd?revision=1.2">
+ <host name='global'>
+   <hostvalue name='arch_string'>-</hostvalue>
+   <hostvalue name='num_proc'>-</hostvalue>
+   <hostvalue name='load_avg'>-</hostvalue>
+   <hostvalue name='mem_total'>-</hostvalue>
+   <hostvalue name='mem_used'>-</hostvalue>
+   <hostvalue name='swap_total'>-</hostvalue>
+   <hostvalue name='swap_used'>-</hostvalue>
+ </host>
+ <host name='node01'>
+   <hostvalue name='arch_string'>lx26-amd64</hostvalue>
+   <hostvalue name='num_proc'>8</hostvalue>
+   <hostvalue name='load_avg'>7.52</hostvalue>
+   <hostvalue name='mem_total'>16.0G</hostvalue>
+   <hostvalue name='mem_used'>1.5G</hostvalue>
+   <hostvalue name='swap_total'>2.0G</hostvalue>
+   <hostvalue name='swap_used'>512.0M</hostvalue>
+ </host>
+ <host name='node02'>
+   <hostvalue name='arch_string'>lx26-amd64</hostvalue>
+   <hostvalue name='num_proc'>4</hostvalue>
+   <hostvalue name='load_avg'>-</hostvalue>
+   <hostvalue name='mem_total'>8.0G</hostvalue>
+   <hostvalue name='mem_used'>-</hostvalue>
+   <hostvalue name='swap_total'>0.0</hostvalue>
+   <hostvalue name='swap_used'>-</hostvalue>
+ </host>
+</qhost>
+`
+
+func TestGetHosts(t *testing.T) {
+	r := &fakeRunner{output: []byte(hostInfo)}
+	defer useRunner(r)()
+
+	hosts, err := GetHosts()
+	if err != nil {
+		t.Fatalf("GetHosts failed: %s", err)
+	}
+	expected := []Host{
+		{Name: "node01", Arch: "lx26-amd64", NumProc: 8, LoadAvg: 7.52, MemTotal: 16 << 30, MemUsed: 1.5 * (1 << 30), SwapTotal: 2 << 30, SwapUsed: 512 << 20},
+		{Name: "node02", Arch: "lx26-amd64", NumProc: 4, LoadAvg: -1, MemTotal: 8 << 30, MemUsed: -1, SwapTotal: 0, SwapUsed: -1},
+	}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("got %+v, expected %+v", hosts, expected)
+	}
+	if cmd := r.commands[0]; cmd.name != "qhost" || !reflect.DeepEqual(cmd.args, []string{"-xml"}) {
+		t.Errorf("ran %s %q, expected qhost -xml", cmd.name, cmd.args)
+	}
+}
+
+// hostResourceInfo is qhost -F -xml output for a GPU host
+const hostResourceInfo = `<?xml version='1.0'?>
+<qhost xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qhost/qhost.xsd?revision=1.2">
+ <host name='global'>
+   <hostvalue name='arch_string'>-</hostvalue>
+ </host>
+ <host name='gpu01'>
+   <hostvalue name='arch_string'>lx26-amd64</hostvalue>
+   <hostvalue name='num_proc'>16</hostvalue>
+   <hostvalue name='load_avg'>3.10</hostvalue>
+   <hostvalue name='mem_total'>64.0G</hostvalue>
+   <hostvalue name='mem_used'>10.0G</hostvalue>
+   <hostvalue name='swap_total'>0.0</hostvalue>
+   <hostvalue name='swap_used'>0.0</hostvalue>
+   <resourcevalue name='arch' dominance='hl'>lx26-amd64</resourcevalue>
+   <resourcevalue name='mem_free' dominance='hl'>54.000G</resourcevalue>
+   <resourcevalue name='gpu' dominance='hc'>2.000000</resourcevalue>
+ </host>
+</qhost>
+`
+
+func TestGetHostResources(t *testing.T) {
+	r := &fakeRunner{output: []byte(hostResourceInfo)}
+	defer useRunner(r)()
+
+	hosts, err := GetHostResources()
+	if err != nil {
+		t.Fatalf("GetHostResources failed: %s", err)
+	}
+	if len(hosts) != 1 || hosts[0].Name != "gpu01" || hosts[0].NumProc != 16 {
+		t.Fatalf("unexpected hosts: %+v", hosts)
+	}
+	expected := []HostResource{
+		{"arch", "lx26-amd64", "hl"},
+		{"mem_free", "54.000G", "hl"},
+		{"gpu", "2.000000", "hc"},
+	}
+	if !reflect.DeepEqual(hosts[0].Resources, expected) {
+		t.Errorf("got resources %+v, expected %+v", hosts[0].Resources, expected)
+	}
+	gpu, ok := hosts[0].Resource("gpu")
+	if !ok {
+		t.Fatalf("no gpu resource")
+	}
+	if n, err := gpu.Number(); err != nil || n != 2 {
+		t.Errorf("got %v, %v free GPUs, expected 2", n, err)
+	}
+	mem, _ := hosts[0].Resource("mem_free")
+	if n, err := mem.Number(); err != nil || n != 54<<30 {
+		t.Errorf("got %v, %v bytes free, expected %d", n, err, 54<<30)
+	}
+	if _, ok := hosts[0].Resource("h_vmem"); ok {
+		t.Errorf("found resource h_vmem, expected none")
+	}
+	if cmd := r.commands[0]; !reflect.DeepEqual(cmd.args, []string{"-xml", "-F"}) {
+		t.Errorf("ran qhost %q, expected qhost -xml -F", cmd.args)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPermissionDenied is matched with errors.Is by the errors of commands which GridEngine refused to carry out
+// because the user is not a manager or operator, or does not own the queue or job.
+// The error also wraps the command's own error, usually a *QstatError including what it printed.
+var ErrPermissionDenied = errors.New("qstat: permission denied")
+
+// permissionMessages are parts of the messages GridEngine commands print when the user lacks the privileges for a request
+var permissionMessages = []string{
+	"must be manager",
+	"must be operator",
+	"necessary privileges",
+	"permission denied",
+}
+
+// checkPermission returns err, or if the output of the command which returned it says that the request
+// was refused for lack of privileges, an error also matching ErrPermissionDenied. Some commands exit successfully
+// even then, so err may be nil.
+func checkPermission(name string, out []byte, err error) error {
+	msgs := string(out)
+	var qerr *QstatError
+	if errors.As(err, &qerr) {
+		msgs += "\n" + qerr.Stderr
+	}
+	for _, line := range strings.Split(msgs, "\n") {
+		lower := strings.ToLower(line)
+		for _, m := range permissionMessages {
+			if strings.Contains(lower, m) {
+				if err == nil {
+					err = fmt.Errorf("%s: %s", name, strings.TrimSpace(line))
+				}
+				return fmt.Errorf("%w: %w", ErrPermissionDenied, err)
+			}
+		}
+	}
+	return err
+}
+
+// qmod runs qmod with an option acting on a queue
+func (c *Client) qmod(option, queue string) error {
+	if queue == "" {
+		return fmt.Errorf("qmod: no queue given")
+	}
+	out, err := c.output(context.Background(), "qmod", option, queue)
+	return checkPermission("qmod", out, err)
+}
+
+// QmodClearError clears the error state of a queue, which may be a cluster queue, eg: all.q, or a queue instance,
+// eg: all.q@node01, by running qmod -cq.
+// If the user isn't allowed to modify the queue the error matches ErrPermissionDenied.
+func (c *Client) QmodClearError(queue string) error {
+	return c.qmod("-cq", queue)
+}
+
+// QmodDisable disables a queue so no more jobs are scheduled to it, by running qmod -d.
+// Jobs already running in the queue continue to run.
+func (c *Client) QmodDisable(queue string) error {
+	return c.qmod("-d", queue)
+}
+
+// QmodEnable enables a disabled queue, by running qmod -e
+func (c *Client) QmodEnable(queue string) error {
+	return c.qmod("-e", queue)
+}
+
+// QmodClearError clears the error state of a queue using DefaultClient
+func QmodClearError(queue string) error {
+	return DefaultClient.QmodClearError(queue)
+}
+
+// QmodDisable disables a queue using DefaultClient
+func QmodDisable(queue string) error {
+	return DefaultClient.QmodDisable(queue)
+}
+
+// QmodEnable enables a queue using DefaultClient
+func QmodEnable(queue string) error {
+	return DefaultClient.QmodEnable(queue)
+}
@@ -0,0 +1,61 @@
+package qstat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQmod(t *testing.T) {
+	r := &fakeRunner{output: []byte(`bob@master changed state of "all.q@node01" (disabled)` + "\n")}
+	c := &Client{Runner: r}
+	tests := []struct {
+		name   string
+		qmod   func(string) error
+		option string
+	}{
+		{"clear error", c.QmodClearError, "-cq"},
+		{"disable", c.QmodDisable, "-d"},
+		{"enable", c.QmodEnable, "-e"},
+	}
+	for _, test := range tests {
+		r.commands = nil
+		if err := test.qmod("all.q@node01"); err != nil {
+			t.Errorf("%s: failed: %s", test.name, err)
+		}
+		expected := []fakeCommand{{"qmod", []string{test.option, "all.q@node01"}}}
+		if !reflect.DeepEqual(r.commands, expected) {
+			t.Errorf("%s: got commands %v, expected %v", test.name, r.commands, expected)
+		}
+		if err := test.qmod(""); err == nil {
+			t.Errorf("%s: expected error for no queue", test.name)
+		}
+	}
+}
+
+func TestQmodPermissionDenied(t *testing.T) {
+	qerr := &QstatError{ExitCode: 1, Stderr: `bob - must be manager or operator or owner of queue "all.q@node01"`, Args: []string{"qmod"}}
+	r := &fakeRunner{err: qerr}
+	c := &Client{Runner: r}
+	err := c.QmodDisable("all.q@node01")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("got error %v, expected ErrPermissionDenied", err)
+	}
+	var got *QstatError
+	if !errors.As(err, &got) || got != qerr {
+		t.Errorf("got error %v, expected it to wrap the qmod failure", err)
+	}
+
+	// qmod may exit successfully after refusing a request
+	r.err = nil
+	r.output = []byte(`bob - you do not have the necessary privileges to modify queue "all.q@node01"` + "\n")
+	if err := c.QmodEnable("all.q@node01"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("got error %v, expected ErrPermissionDenied", err)
+	}
+
+	r.err = &QstatError{ExitCode: 1, Stderr: `error: invalid queue "nosuch.q"`, Args: []string{"qmod"}}
+	r.output = nil
+	if err := c.QmodEnable("nosuch.q"); err == nil || errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("got error %v, expected a failure other than ErrPermissionDenied", err)
+	}
+}
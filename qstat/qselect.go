@@ -0,0 +1,53 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"strings"
+)
+
+// SelectOptions are the constraints of a qselect query. Zero valued fields do not constrain the result.
+// See man 1 qselect for a description of the options.
+type SelectOptions struct {
+	Resources map[string]string `json:"resources"` // Resources the queue instances must offer (-l)
+	PE        string            `json:"pe"`        // A parallel environment the queue instances must be attached to (-pe)
+	Queue     string            `json:"queue"`     // The queues or queue instances to select from, eg: all.q or *@node01 (-q)
+}
+
+// args returns the qselect arguments for the options
+func (o SelectOptions) args() []string {
+	var args []string
+	if len(o.Resources) > 0 {
+		args = append(args, "-l", resourceList(o.Resources))
+	}
+	if o.PE != "" {
+		args = append(args, "-pe", o.PE)
+	}
+	if o.Queue != "" {
+		args = append(args, "-q", o.Queue)
+	}
+	return args
+}
+
+// Qselect returns the names of the queue instances matching the options, eg: all.q@node01, by running qselect.
+func (c *Client) Qselect(opts SelectOptions) ([]string, error) {
+	out, err := c.output(context.Background(), "qselect", opts.args()...)
+	if err != nil {
+		return nil, err
+	}
+	var queues []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			queues = append(queues, line)
+		}
+	}
+	return queues, nil
+}
+
+// Qselect returns the names of the queue instances matching the options using DefaultClient
+func Qselect(opts SelectOptions) ([]string, error) {
+	return DefaultClient.Qselect(opts)
+}
@@ -0,0 +1,36 @@
+package qstat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQselect(t *testing.T) {
+	r := &fakeRunner{output: []byte("gpu.q@node01\ngpu.q@node02\n\n")}
+	c := &Client{Runner: r}
+	opts := SelectOptions{
+		Resources: map[string]string{"gpu": "1", "arch": "lx26-amd64"},
+		PE:        "smp",
+		Queue:     "gpu.q",
+	}
+	qs, err := c.Qselect(opts)
+	if err != nil {
+		t.Fatalf("Qselect failed: %s", err)
+	}
+	if expected := []string{"gpu.q@node01", "gpu.q@node02"}; !reflect.DeepEqual(qs, expected) {
+		t.Errorf("got %q, expected %q", qs, expected)
+	}
+	expected := []string{"-l", "arch=lx26-amd64,gpu=1", "-pe", "smp", "-q", "gpu.q"}
+	if cmd := r.commands[0]; cmd.name != "qselect" || !reflect.DeepEqual(cmd.args, expected) {
+		t.Errorf("ran %s %q, expected qselect %q", cmd.name, cmd.args, expected)
+	}
+
+	r.output = nil
+	r.commands = nil
+	if qs, err := c.Qselect(SelectOptions{}); err != nil || qs != nil {
+		t.Errorf("got %q, %v, expected no queues", qs, err)
+	}
+	if args := r.commands[0].args; len(args) != 0 {
+		t.Errorf("ran qselect %q, expected no arguments", args)
+	}
+}
@@ -6,24 +6,30 @@
 package qstat
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"github.com/kisielk/gorge/util"
 	"math"
-	"os/exec"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/kisielk/gorge/util"
 )
 
 // Resource represents a GridEngine resource request
 // See man 5 sge_complex for a more detailed description of the fields
 type Resource struct {
 	Name        string  `json:"name" xml:"CE_name"`                // The name of the complex resource
-	ValType     int     `json:"valType" xml:"CE_valtype"`          // The type of value
+	ValType     int     `json:"valType" xml:"CE_valtype"`          // The type of value, one of the ValType constants
 	StringVal   string  `json:"stringVal" xml:"CE_stringval"`      // The value as a string
 	DoubleVal   float64 `json:"doubleVal" xml:"CE_doubleval"`      // The value as a double
-	RelOp       int     `json:"relOp" xml:"CE_relop"`              // The relation operator used to compare the value
+	RelOp       int     `json:"relOp" xml:"CE_relop"`              // The relation operator used to compare the value, one of the RelOp constants
 	Consumable  bool    `json:"consumable" xml:"CE_consumable"`    // True if the resource is a consumable resourece
 	Dominant    bool    `json:"dominant" xml:"CE_dominant"`        // ?
 	PJDoubleVal float64 `json:"pjDoubleVal" xml:"CE_pj_doubleval"` // ?
@@ -32,6 +38,93 @@ type Resource struct {
 	Tagged      bool    `json:"tagged" xml:"CE_tagged"`            // ?
 }
 
+// ValType is the type of the value of a complex resource
+type ValType int
+
+const (
+	ValTypeInt              ValType = 1
+	ValTypeString           ValType = 2
+	ValTypeTime             ValType = 3
+	ValTypeMemory           ValType = 4
+	ValTypeBool             ValType = 5
+	ValTypeCString          ValType = 6 // A case insensitive string
+	ValTypeHost             ValType = 7
+	ValTypeDouble           ValType = 8
+	ValTypeRestrictedString ValType = 9 // A string matched with wildcards and alternatives, eg: lx*|sol*
+)
+
+var valTypeNames = map[ValType]string{
+	ValTypeInt:              "INT",
+	ValTypeString:           "STRING",
+	ValTypeTime:             "TIME",
+	ValTypeMemory:           "MEMORY",
+	ValTypeBool:             "BOOL",
+	ValTypeCString:          "CSTRING",
+	ValTypeHost:             "HOST",
+	ValTypeDouble:           "DOUBLE",
+	ValTypeRestrictedString: "RESTRING",
+}
+
+// String returns the name of the type as used by qconf -sc, eg: MEMORY
+func (t ValType) String() string {
+	if name, ok := valTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("ValType(%d)", int(t))
+}
+
+// RelOp is the relation operator used to compare a complex resource value to a request
+type RelOp int
+
+const (
+	RelOpEQ   RelOp = 1
+	RelOpGE   RelOp = 2
+	RelOpGT   RelOp = 3
+	RelOpLT   RelOp = 4
+	RelOpLE   RelOp = 5
+	RelOpNE   RelOp = 6
+	RelOpEXCL RelOp = 7 // Exclusive use of a consumable
+)
+
+var relOpNames = map[RelOp]string{
+	RelOpEQ:   "==",
+	RelOpGE:   ">=",
+	RelOpGT:   ">",
+	RelOpLT:   "<",
+	RelOpLE:   "<=",
+	RelOpNE:   "!=",
+	RelOpEXCL: "EXCL",
+}
+
+// String returns the operator as used by qconf -sc, eg: <=
+func (op RelOp) String() string {
+	if name, ok := relOpNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("RelOp(%d)", int(op))
+}
+
+// ValTypeString returns the name of the resource's value type, eg: MEMORY
+func (r Resource) ValTypeString() string {
+	return ValType(r.ValType).String()
+}
+
+// RelOpString returns the resource's relation operator, eg: <=
+func (r Resource) RelOpString() string {
+	return RelOp(r.RelOp).String()
+}
+
+// MarshalJSON encodes the resource with the names of its value type and relation operator
+// alongside their numeric values.
+func (r Resource) MarshalJSON() ([]byte, error) {
+	type resource Resource
+	return json.Marshal(struct {
+		resource
+		ValTypeName string `json:"valTypeName"`
+		RelOpName   string `json:"relOpName"`
+	}{resource(r), r.ValTypeString(), r.RelOpString()})
+}
+
 // MailAddress represents an email address
 type MailAddress struct {
 	User string `json:"user" xml:"MR_user"`
@@ -63,14 +156,81 @@ type TaskIDRange struct {
 	Step int `json:"step" xml:"RN_step"` // The ID step size between tasks
 }
 
-// NumTasks returns the number of tasks in a range
+// NumTasks returns the number of tasks in a range.
+// A range with a step below 1 is invalid and has no tasks.
 func (r TaskIDRange) NumTasks() int {
+	if r.Step < 1 {
+		return 0
+	}
 	min := float64(r.Min)
 	max := float64(r.Max)
 	step := float64(r.Step)
 	return int(math.Ceil((max - min + 1) / step))
 }
 
+// Tasks returns the IDs of the tasks in the range, in increasing order.
+// A slice of NumTasks ints is allocated, which for a range like 1-75000 is large.
+// An invalid range, with a step below 1 or a maximum below the minimum, has no tasks.
+func (r TaskIDRange) Tasks() []int {
+	if r.Step < 1 || r.Max < r.Min {
+		return nil
+	}
+	ids := make([]int, 0, (r.Max-r.Min)/r.Step+1)
+	for id := r.Min; id <= r.Max; id += r.Step {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Contains reports whether the task with the given ID is in the range, taking the step in to account.
+// Unlike Tasks it does not allocate, so it should be used to test for a single ID.
+func (r TaskIDRange) Contains(id int) bool {
+	if r.Step < 1 || id < r.Min || id > r.Max {
+		return false
+	}
+	return (id-r.Min)%r.Step == 0
+}
+
+// String returns the range expression for r in the form accepted by NewTaskIDRange
+func (r TaskIDRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	if r.Step == 1 {
+		return fmt.Sprintf("%d-%d", r.Min, r.Max)
+	}
+	return fmt.Sprintf("%d-%d:%d", r.Min, r.Max, r.Step)
+}
+
+// MarshalJSON encodes the range as its range expression, eg: "1-10:3"
+func (r TaskIDRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON decodes a range expression as accepted by NewTaskIDRange.
+// An object with min, max and step fields is also accepted.
+func (r *TaskIDRange) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		type taskIDRange TaskIDRange
+		return json.Unmarshal(b, (*taskIDRange)(r))
+	}
+	parsed, err := NewTaskIDRange(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// validate returns an error if r is not a range of task IDs GridEngine would accept
+func (r TaskIDRange) validate() error {
+	if r.Min < 1 || r.Max < r.Min || r.Step < 1 {
+		return fmt.Errorf("invalid task id range %d-%d:%d", r.Min, r.Max, r.Step)
+	}
+	return nil
+}
+
 // NewTaskIDRange initializes a TaskIDRange from a string range expression.
 // The range expression is in one of the forms:
 //
@@ -111,6 +271,9 @@ func NewTaskIDRange(s string) (TaskIDRange, error) {
 			if err != nil {
 				return TaskIDRange{}, fmt.Errorf("could not parse: invalid step (%s)", tail[1])
 			}
+			if step < 1 {
+				return TaskIDRange{}, fmt.Errorf("could not parse: step must be positive (%s)", tail[1])
+			}
 		}
 		max, err = strconv.ParseInt(tail[0], 10, 64)
 		if err != nil {
@@ -121,8 +284,13 @@ func NewTaskIDRange(s string) (TaskIDRange, error) {
 	return TaskIDRange{int(min), int(max), int(step)}, nil
 }
 
-// ParseTaskIDRanges creates a slice of TaskIDRange based on the string s
+// ParseTaskIDRanges creates a slice of TaskIDRange based on the string s, a comma separated list of
+// range expressions as accepted by NewTaskIDRange.
+// An empty string is an empty list and returns no ranges, unlike NewTaskIDRange which treats it as task 1.
 func ParseTaskIDRanges(s string) ([]TaskIDRange, error) {
+	if s == "" {
+		return []TaskIDRange{}, nil
+	}
 	rangeStrings := strings.Split(s, ",")
 	ranges := []TaskIDRange{}
 	for _, r := range rangeStrings {
@@ -151,10 +319,121 @@ type SMEMessage struct {
 	Message    string `json:"message" xml:"MES_message"`
 }
 
+// UsageValue is one entry of a task's usage list
+type UsageValue struct {
+	Name  string  `json:"name" xml:"UA_name"`
+	Value float64 `json:"value" xml:"UA_value"`
+}
+
+// Usage is the resource usage of a running task
+type Usage struct {
+	WallClock float64 `json:"wallclock"` // Wall clock time in seconds
+	CPU       float64 `json:"cpu"`       // CPU time in seconds
+	Mem       float64 `json:"mem"`       // Integral memory usage in GB * seconds
+	IO        float64 `json:"io"`        // Data transferred in GB
+	IOWait    float64 `json:"ioWait"`    // IO wait time in seconds
+	VMem      float64 `json:"vmem"`      // Current virtual memory in bytes
+	MaxVMem   float64 `json:"maxVmem"`   // Maximum virtual memory in bytes
+}
+
 type Task struct {
-	Status      int          `json:"status" xml:"JAT_status"`
+	Status      int          `json:"status" xml:"JAT_status"` // The task status, a combination of the TaskStatus values
 	TaskNumber  int          `json:"taskNumber" xml:"JAT_task_number"`
+	StartTime   int          `json:"startTime" xml:"JAT_start_time"` // The time the task started, in seconds since the epoch, 0 if it hasn't
 	MessageList []JATMessage `json:"messageList" xml:"JAT_message_list>ulong_sublist"`
+	ScaledUsage []UsageValue `json:"scaledUsage" xml:"JAT_scaled_usage_list>scaled"` // Usage of a running task, scaled by the host's usage_scaling
+}
+
+// TaskStatus is a bit of the JAT_status of a task, as defined in the GridEngine job headers.
+type TaskStatus int
+
+const (
+	TaskIdle              TaskStatus = 0x00000000 // Not started yet
+	TaskHeld              TaskStatus = 0x00000010
+	TaskMigrating         TaskStatus = 0x00000020
+	TaskQueued            TaskStatus = 0x00000040
+	TaskRunning           TaskStatus = 0x00000080
+	TaskSuspended         TaskStatus = 0x00000100
+	TaskTransferring      TaskStatus = 0x00000200 // Being sent to its execution host
+	TaskDeleted           TaskStatus = 0x00000400
+	TaskWaiting           TaskStatus = 0x00000800
+	TaskExiting           TaskStatus = 0x00001000 // Finished running, waiting for its accounting to be written
+	TaskWritten           TaskStatus = 0x00002000
+	TaskWaitingForOSJobID TaskStatus = 0x00004000
+	TaskError             TaskStatus = 0x00008000
+	TaskFinished          TaskStatus = 0x00010000
+	TaskSlave             TaskStatus = 0x00020000 // A slave task of a parallel job
+)
+
+// taskStatusNames are the names of the TaskStatus bits, in bit order
+var taskStatusNames = []struct {
+	status TaskStatus
+	name   string
+}{
+	{TaskHeld, "held"},
+	{TaskMigrating, "migrating"},
+	{TaskQueued, "queued"},
+	{TaskRunning, "running"},
+	{TaskSuspended, "suspended"},
+	{TaskTransferring, "transferring"},
+	{TaskDeleted, "deleted"},
+	{TaskWaiting, "waiting"},
+	{TaskExiting, "exiting"},
+	{TaskWritten, "written"},
+	{TaskWaitingForOSJobID, "waiting for os job id"},
+	{TaskError, "error"},
+	{TaskFinished, "finished"},
+	{TaskSlave, "slave"},
+}
+
+// HasStatus returns true if the task's status includes s.
+// Every task has the TaskIdle status.
+func (t Task) HasStatus(s TaskStatus) bool {
+	return TaskStatus(t.Status)&s == s
+}
+
+// StatusString returns the names of the task's status bits, separated by |, eg: running.
+// A status of 0 is "idle", and unknown bits are shown in hex.
+func (t Task) StatusString() string {
+	if t.Status == 0 {
+		return "idle"
+	}
+	var names []string
+	rest := TaskStatus(t.Status)
+	for _, n := range taskStatusNames {
+		if rest&n.status != 0 {
+			names = append(names, n.name)
+			rest &^= n.status
+		}
+	}
+	if rest != 0 {
+		names = append(names, fmt.Sprintf("%#x", int(rest)))
+	}
+	return strings.Join(names, "|")
+}
+
+// Usage decodes the scaled usage list of the task. It returns false if the task reports no usage.
+func (t Task) Usage() (Usage, bool) {
+	var u Usage
+	for _, v := range t.ScaledUsage {
+		switch v.Name {
+		case "wallclock":
+			u.WallClock = v.Value
+		case "cpu":
+			u.CPU = v.Value
+		case "mem":
+			u.Mem = v.Value
+		case "io":
+			u.IO = v.Value
+		case "iow":
+			u.IOWait = v.Value
+		case "vmem":
+			u.VMem = v.Value
+		case "maxvmem":
+			u.MaxVMem = v.Value
+		}
+	}
+	return u, len(t.ScaledUsage) > 0
 }
 
 type JobInfo struct {
@@ -177,9 +456,14 @@ type JobInfo struct {
 	JobShare                int           `json:"jobShare" xml:"JB_jobshare"`
 	QstatHardResourceList   []Resource    `json:"qstatHardResourceList" xml:"JB_hard_resource_list>qstat_l_requests"` // One type of hard resource list qstat has. Use HardResourceRequest() to get the full list.
 	ElementHardResourceList []Resource    `json:"elementHardResourceList" xml:"JB_hard_resource_list>element"`        // Another type of hard resource list. Use HardResourceRequest() to get the full list.
+	QstatSoftResourceList   []Resource    `json:"qstatSoftResourceList" xml:"JB_soft_resource_list>qstat_l_requests"` // One type of soft resource list qstat has. Use SoftResourceRequest() to get the full list.
+	ElementSoftResourceList []Resource    `json:"elementSoftResourceList" xml:"JB_soft_resource_list>element"`        // Another type of soft resource list. Use SoftResourceRequest() to get the full list.
+	HardQueueList           []string      `json:"hardQueueList" xml:"JB_hard_queue_list>destin_ident_list>QR_name"`   // The queues requested with qsub -q, which may be wildcard patterns
+	SoftQueueList           []string      `json:"softQueueList" xml:"JB_soft_queue_list>destin_ident_list>QR_name"`   // The queues requested with qsub -soft -q
 	EnvList                 []EnvVar      `json:"envList" xml:"JB_env_list>job_sublist"`
 	JobArgs                 []string      `json:"jobArgs" xml:"JB_job_args>element>ST_name"`
 	ScriptFile              string        `json:"scriptFile" xml:"JB_script_file"`
+	ShellList               []PathList    `json:"shellList" xml:"JB_shell_list>path_list"` // The shells requested with qsub -S, optionally per host
 	JobArrayTasks           []Task        `json:"jobArrayTasks" xml:"JB_ja_tasks>ulong_sublist"`
 	Cwd                     string        `json:"cwd" xml:"JB_cwd"`
 	StderrPathList          []PathList    `json:"stderrPathList" xml:"JB_stderr_path_list>path_list"`
@@ -202,26 +486,362 @@ type JobInfo struct {
 	OverrideTickets         int           `json:"overrideTickets" xml:"JB_override_tickets"`
 	Version                 int           `json:"version" xml:"JB_version"`
 	JobArray                TaskIDRange   `json:"jobArray" xml:"JB_ja_structure>task_id_range"`
+	ParallelEnv             string        `json:"parallelEnv" xml:"JB_pe"`          // The parallel environment requested with qsub -pe, empty for a serial job
+	PERange                 TaskIDRange   `json:"peRange" xml:"JB_pe_range>ranges"` // The range of slots requested in the parallel environment
 	Type                    int           `json:"type" xml:"JB_type"`
 }
 
-// HardResourceList returns the complete list of the hard resource requests made by the job
+// HardResourceList returns the complete list of the hard resource requests made by the job.
+// Some versions of qstat report a request in both of the job's hard resource lists, so each
+// resource is returned once, in the order they were first listed.
 func (i JobInfo) HardResourceRequest() []Resource {
-	resources := make([]Resource, 0, len(i.QstatHardResourceList)+len(i.ElementHardResourceList))
-	for _, r := range i.QstatHardResourceList {
-		resources = append(resources, r)
+	return mergeResources(i.QstatHardResourceList, i.ElementHardResourceList)
+}
+
+// SoftResourceRequest returns the complete list of the soft resource requests made by the job with qsub -soft -l.
+// Like HardResourceRequest, each resource is returned once.
+func (i JobInfo) SoftResourceRequest() []Resource {
+	return mergeResources(i.QstatSoftResourceList, i.ElementSoftResourceList)
+}
+
+// QueueAllowed reports whether the job's hard queue requests allow it to run in the named cluster queue
+// or queue instance, eg: all.q or all.q@node01. A job that requested no queues may run in any queue.
+// Requests are matched as wildcard patterns, and a request without a host matches every instance of the queue.
+// The hosts of a host group are not known, so it is an error if the answer depends on a request for one, eg: all.q@@gpu.
+func (i JobInfo) QueueAllowed(queue string) (bool, error) {
+	if len(i.HardQueueList) == 0 {
+		return true, nil
 	}
-	for _, r := range i.ElementHardResourceList {
-		resources = append(resources, r)
+	return matchQueues(i.HardQueueList, queue)
+}
+
+// QueuePreferred reports whether the named cluster queue or queue instance is one of the job's soft queue
+// requests, matched as for QueueAllowed. The scheduler prefers to run the job in such a queue.
+func (i JobInfo) QueuePreferred(queue string) (bool, error) {
+	return matchQueues(i.SoftQueueList, queue)
+}
+
+// matchQueues reports whether queue matches any of the queue request patterns. The cluster queue and
+// host parts of a request are matched separately, and a request without a cluster queue, eg: @node01,
+// matches every queue on the host. A host group request that could match is an error unless another request does.
+func matchQueues(patterns []string, queue string) (bool, error) {
+	cq, host := splitQueueName(queue)
+	var unknown error
+	for _, p := range patterns {
+		pq, ph := splitQueueName(p)
+		if pq == "" {
+			pq = "*"
+		}
+		ok, err := matchWildcard(pq, cq)
+		if err != nil {
+			return false, fmt.Errorf("qstat: invalid queue request %q: %s", p, err)
+		}
+		if !ok {
+			continue
+		}
+		if !strings.Contains(p, "@") {
+			return true, nil
+		}
+		if host == "" {
+			continue
+		}
+		if strings.HasPrefix(ph, "@") {
+			if unknown == nil {
+				unknown = fmt.Errorf("qstat: can't match queue request %q for host group %s", p, ph[1:])
+			}
+			continue
+		}
+		ok, err = matchWildcard(ph, host)
+		if err != nil {
+			return false, fmt.Errorf("qstat: invalid queue request %q: %s", p, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, unknown
+}
+
+// splitQueueName splits a queue instance name, or queue request, in to its cluster queue and host parts
+func splitQueueName(name string) (string, string) {
+	if i := strings.Index(name, "@"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// matchWildcard reports whether s matches the GridEngine wildcard pattern p, in which * matches any string,
+// ? any character and [...] any of the characters in the brackets. Unlike for path.Match, / is not special.
+func matchWildcard(p, s string) (bool, error) {
+	expr := "^"
+	lit := 0
+	for i := 0; i < len(p); i++ {
+		var sub string
+		end := i
+		switch p[i] {
+		case '*':
+			sub = ".*"
+		case '?':
+			sub = "."
+		case '[':
+			j := strings.IndexByte(p[i+1:], ']')
+			if j < 0 {
+				return false, fmt.Errorf("missing ] in %q", p)
+			}
+			end = i + 1 + j
+			class := p[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sub = "[" + class + "]"
+		default:
+			continue
+		}
+		expr += regexp.QuoteMeta(p[lit:i]) + sub
+		i = end
+		lit = end + 1
+	}
+	re, err := regexp.Compile(expr + regexp.QuoteMeta(p[lit:]) + "$")
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// mergeResources concatenates lists of resource requests, keeping one request for each resource name.
+// Of the requests for the same resource, the one with the most fields set is kept.
+func mergeResources(lists ...[]Resource) []Resource {
+	var resources []Resource
+	index := make(map[string]int)
+	for _, l := range lists {
+		for _, r := range l {
+			name := strings.ToLower(r.Name)
+			if k, ok := index[name]; ok {
+				if r.populated() > resources[k].populated() {
+					resources[k] = r
+				}
+				continue
+			}
+			index[name] = len(resources)
+			resources = append(resources, r)
+		}
+	}
+	if resources == nil {
+		resources = []Resource{}
 	}
 	return resources
 }
 
+// populated returns the number of the value fields of r that are set
+func (r Resource) populated() int {
+	n := 0
+	for _, set := range []bool{r.ValType != 0, r.StringVal != "", r.DoubleVal != 0, r.RelOp != 0} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// Resource returns the hard resource request for the named resource, such as h_vmem, and whether the job made one.
+// Resource names are matched case-insensitively, as GridEngine does.
+func (i JobInfo) Resource(name string) (Resource, bool) {
+	for _, r := range i.HardResourceRequest() {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
 // NumTasks returns the number of tasks in a JobInfo
 func (i JobInfo) NumTasks() int {
 	return i.JobArray.NumTasks()
 }
 
+// IsParallel reports whether the job requested a parallel environment with qsub -pe
+func (i JobInfo) IsParallel() bool {
+	return i.ParallelEnv != ""
+}
+
+// Shell returns the shell the job requested with qsub -S. If the shell list has entries for
+// specific hosts, the entry without a host is preferred, falling back to the first entry.
+// An empty string means no shell was requested and the queue's configured shell is used.
+func (i JobInfo) Shell() string {
+	for _, p := range i.ShellList {
+		if p.Host == "" {
+			return p.Path
+		}
+	}
+	if len(i.ShellList) > 0 {
+		return i.ShellList[0].Path
+	}
+	return ""
+}
+
+// EnvMap returns the job's environment variables keyed by name.
+// If a variable is listed more than once the last value wins, as it would when the environment is set up.
+func (i JobInfo) EnvMap() map[string]string {
+	env := make(map[string]string, len(i.EnvList))
+	for _, v := range i.EnvList {
+		env[v.Variable] = v.Value
+	}
+	return env
+}
+
+// Env returns the value of the named environment variable of the job, and whether it is set.
+// Like EnvMap, the last value of a variable listed more than once is returned.
+func (i JobInfo) Env(name string) (string, bool) {
+	for k := len(i.EnvList) - 1; k >= 0; k-- {
+		if i.EnvList[k].Variable == name {
+			return i.EnvList[k].Value, true
+		}
+	}
+	return "", false
+}
+
+// BusiestTask returns the number and usage of the task of an array job with the highest CPU usage.
+// ok is false if the job is not an array job or none of its tasks report usage.
+func (i JobInfo) BusiestTask() (taskNumber int, usage Usage, ok bool) {
+	if i.NumTasks() <= 1 {
+		return 0, Usage{}, false
+	}
+	for _, t := range i.JobArrayTasks {
+		u, hasUsage := t.Usage()
+		if hasUsage && (!ok || u.CPU > usage.CPU) {
+			taskNumber, usage, ok = t.TaskNumber, u, true
+		}
+	}
+	return taskNumber, usage, ok
+}
+
+// unixTime converts a time in seconds since the epoch to a time.Time, with 0 for the zero time
+func unixTime(secs int) time.Time {
+	if secs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(secs), 0)
+}
+
+// SubmissionTimeParsed returns the time the job was submitted
+func (i JobInfo) SubmissionTimeParsed() time.Time {
+	return unixTime(i.SubmissionTime)
+}
+
+// ExecutionTimeParsed returns the earliest time the job may start, as requested with qsub -a.
+// It is the zero time if the job did not request one.
+func (i JobInfo) ExecutionTimeParsed() time.Time {
+	return unixTime(i.ExecutionTime)
+}
+
+// Runtime returns how long the job has been running at now, measured from the earliest start time of its tasks.
+// It is 0 if none of the job's tasks have started.
+func (i JobInfo) Runtime(now time.Time) time.Duration {
+	var start time.Time
+	for _, t := range i.JobArrayTasks {
+		s := unixTime(t.StartTime)
+		if !s.IsZero() && (start.IsZero() || s.Before(start)) {
+			start = s
+		}
+	}
+	if start.IsZero() || now.Before(start) {
+		return 0
+	}
+	return now.Sub(start)
+}
+
+// The bits of JobInfo.MailOptions, set with the qsub -m option
+const (
+	MailAtAbort      = 0x00040000 // Mail is sent when the job is aborted or rescheduled (-m a)
+	MailAtBeginning  = 0x00080000 // Mail is sent when the job begins (-m b)
+	MailAtExit       = 0x00100000 // Mail is sent when the job ends (-m e)
+	NoMail           = 0x00200000 // No mail is sent (-m n)
+	MailAtSuspension = 0x00400000 // Mail is sent when the job is suspended (-m s)
+)
+
+// MailOptions are the events a job sends mail about
+type MailOptions struct {
+	Begin   bool `json:"begin"`   // When the job begins
+	End     bool `json:"end"`     // When the job ends
+	Abort   bool `json:"abort"`   // When the job is aborted or rescheduled
+	Suspend bool `json:"suspend"` // When the job is suspended
+	None    bool `json:"none"`    // No mail is sent
+}
+
+// MailOptionsParsed decodes the job's mail options bitmask.
+// None is true if no mail is sent, either because -m n was given or because no events were selected.
+func (i JobInfo) MailOptionsParsed() MailOptions {
+	m := MailOptions{
+		Begin:   i.MailOptions&MailAtBeginning != 0,
+		End:     i.MailOptions&MailAtExit != 0,
+		Abort:   i.MailOptions&MailAtAbort != 0,
+		Suspend: i.MailOptions&MailAtSuspension != 0,
+	}
+	m.None = i.MailOptions&NoMail != 0 || !(m.Begin || m.End || m.Abort || m.Suspend)
+	return m
+}
+
+// The bits of JobInfo.Type
+const (
+	JobTypeImmediate = 0x001 // The job must be scheduled immediately or fail (-now y)
+	JobTypeQsh       = 0x002 // Submitted with qsh
+	JobTypeQlogin    = 0x004 // Submitted with qlogin
+	JobTypeQrsh      = 0x008 // Submitted with qrsh
+	JobTypeQmake     = 0x010 // Submitted with qmake
+	JobTypeQrlogin   = 0x020 // Submitted with qrsh without a command
+	JobTypeNoError   = 0x040 // The job's exit status does not put it in error state
+	JobTypeBinary    = 0x080 // The job is a binary rather than a script (-b y)
+	JobTypeArray     = 0x100 // The job is an array job (-t)
+	JobTypeNoShell   = 0x200 // The job is started without a shell (-shell n)
+)
+
+// JobType is a job's type decoded in to its individual flags
+type JobType struct {
+	Immediate   bool `json:"immediate"`   // The job must be scheduled immediately
+	Interactive bool `json:"interactive"` // The job was submitted with qsh, qlogin, qrsh or qmake
+	Binary      bool `json:"binary"`      // The job is a binary
+	Array       bool `json:"array"`       // The job is an array job
+	NoShell     bool `json:"noShell"`     // The job is started without a shell
+}
+
+// TypeParsed decodes the job type bitmask
+func (i JobInfo) TypeParsed() JobType {
+	return JobType{
+		Immediate:   i.Type&JobTypeImmediate != 0,
+		Interactive: i.Type&(JobTypeQsh|JobTypeQlogin|JobTypeQrsh|JobTypeQmake|JobTypeQrlogin) != 0,
+		Binary:      i.Type&JobTypeBinary != 0,
+		Array:       i.Type&JobTypeArray != 0,
+		NoShell:     i.Type&JobTypeNoShell != 0,
+	}
+}
+
+// IsArray returns true if the job is an array job, either because its type says so or because
+// its task range spans more than one task. An array job submitted with a single task, eg: qsub -t 1,
+// is an array job with NumTasks of 1.
+func (i JobInfo) IsArray() bool {
+	return i.TypeParsed().Array || i.NumTasks() > 1
+}
+
+// IsInteractive returns true if the job is an interactive job
+func (i JobInfo) IsInteractive() bool {
+	return i.TypeParsed().Interactive
+}
+
+// RestartPolicy is the rerun policy of a job as requested with qsub -r
+type RestartPolicy int
+
+const (
+	RestartUnspecified RestartPolicy = 0 // No -r option given, the queue's rerun setting applies
+	RestartYes         RestartPolicy = 1 // The job is rerunnable (-r y)
+	RestartNo          RestartPolicy = 2 // The job is not rerunnable (-r n)
+)
+
+// Rerunnable returns the rerun policy of the job, which determines whether it will be
+// automatically restarted if its execution host fails.
+func (i JobInfo) Rerunnable() (policy RestartPolicy) {
+	return RestartPolicy(i.Restart)
+}
+
 // DetailedJobInfo represents the job information returned by qstat -j
 type DetailedJobInfo struct {
 	Jobs     []JobInfo `xml:"djob_info>element"`
@@ -230,36 +850,54 @@ type DetailedJobInfo struct {
 
 // QueueJob represents data about one job in the queue overview
 type QueueJob struct {
-	JobNumber            int     `json:"jobNumber" xml:"JB_job_number"`           // Unique job number
-	POSIXPriority        int     `json:"posixPriority" xml:"JB_priority"`         //  Relative importance due to Posix priority in the range between 0.0 and 1.0
-	NormalizedUrgency    float64 `json:"normalizedUrgency" xml:"JB_nurg"`         // Relative importance due to static urgency in the range between 0.0 and 1.0
-	NormalizedPriority   float64 `json:"normalizedPriority" xml:"JAT_prio"`       // The GE priority derived from weighted normalized tickets and weighted normalized static urgency
-	NormalizedTickets    float64 `json:"normalizedTickets" xml:"JAT_ntix"`        //  Relative importance due to JAT_tix amount in the range between 0.0 and 1.0.
-	ResourceContribution float64 `json:"resourceContribution" xml:"JB_rrcontr"`   //  Combined contribution to static urgency from all resources.
-	DeadlineContribution float64 `json:"deadlineContribution" xml:"JB_dlcontr"`   // Contribution to static urgency from job deadline.
-	WaitTimeContribution float64 `json:"waitTimeContribution" xml:"JB_wtcontr"`   // Contribution to static urgency from waiting time.
-	Name                 string  `json:"name" xml:"JB_name"`                      // Job name
-	Owner                string  `json:"owner" xml:"JB_owner"`                    // Owner of the job
-	Project              string  `json:"project" xml:"JB_project"`                // Project name
-	Department           string  `json:"department" xml:"JB_department"`          // Department name
-	State                string  `json:"state" xml:"state"`                       // State string
-	StartTime            string  `json:"startTime" xml:"JAT_start_time"`          // Task start time
-	SubmissionTime       string  `json:"submissionTime" xml:"JB_submission_time"` // Time the job was submitted
-	CPUUsage             float64 `json:"cpuUsage" xml:"cpu_usage"`                // CPU usage in seconds
-	MemUsage             float64 `json:"memUsage" xml:"mem_usage"`                // Memory usage in MB * seconds
-	IOUsage              float64 `json:"ioUsage" xml:"io_usage"`                  // IO usage in MB
-	Tickets              int     `json:"tickets" xml:"tickets"`                   // Number of assigned tickets
-	OverrideTickets      int     `json:"overrideTickets" xml:"otickets"`          // Number of assigned override tickets
-	FairshareTickets     int     `json:"fairShareTickets" xml:"ftickets"`         // Number of assigned fairshare tickets
-	ShareTreeTickets     int     `json:"shareTreeTickets" xml:"stickets"`         // Number of assigned sharetree tickets
-	QueueName            string  `json:"queueName" xml:"queue_name"`              // Queue in which the job is executing
-	Slots                int     `json:"slots" xml:"slots"`                       // Number of slots
-	Tasks                string  `json:"tasks" xml:"tasks"`                       // Task string
+	JobNumber               int     `json:"jobNumber" xml:"JB_job_number"`           // Unique job number
+	POSIXPriority           int     `json:"posixPriority" xml:"JB_priority"`         //  Relative importance due to Posix priority in the range between 0.0 and 1.0
+	NormalizedUrgency       float64 `json:"normalizedUrgency" xml:"JB_nurg"`         // Relative importance due to static urgency in the range between 0.0 and 1.0
+	Urgency                 float64 `json:"urgency" xml:"JB_urg"`                    // The static urgency, the sum of the resource, deadline and wait time contributions
+	NormalizedPriority      float64 `json:"normalizedPriority" xml:"JAT_prio"`       // The GE priority derived from weighted normalized tickets and weighted normalized static urgency
+	NormalizedTickets       float64 `json:"normalizedTickets" xml:"JAT_ntix"`        //  Relative importance due to JAT_tix amount in the range between 0.0 and 1.0.
+	NormalizedPriorityPosix float64 `json:"normalizedPriorityPosix" xml:"JB_nppri"`  // Relative importance due to Posix priority in the range between 0.0 and 1.0
+	ResourceContribution    float64 `json:"resourceContribution" xml:"JB_rrcontr"`   //  Combined contribution to static urgency from all resources.
+	DeadlineContribution    float64 `json:"deadlineContribution" xml:"JB_dlcontr"`   // Contribution to static urgency from job deadline.
+	WaitTimeContribution    float64 `json:"waitTimeContribution" xml:"JB_wtcontr"`   // Contribution to static urgency from waiting time.
+	Name                    string  `json:"name" xml:"JB_name"`                      // Job name
+	Owner                   string  `json:"owner" xml:"JB_owner"`                    // Owner of the job
+	Project                 string  `json:"project" xml:"JB_project"`                // Project name
+	Department              string  `json:"department" xml:"JB_department"`          // Department name
+	State                   string  `json:"state" xml:"state"`                       // State string
+	ListState               string  `json:"listState" xml:"state,attr"`              // The state attribute of the job list entry, running or pending
+	StartTime               string  `json:"startTime" xml:"JAT_start_time"`          // Task start time
+	SubmissionTime          string  `json:"submissionTime" xml:"JB_submission_time"` // Time the job was submitted
+	CPUUsage                float64 `json:"cpuUsage" xml:"cpu_usage"`                // CPU usage in seconds
+	MemUsage                float64 `json:"memUsage" xml:"mem_usage"`                // Memory usage in MB * seconds
+	IOUsage                 float64 `json:"ioUsage" xml:"io_usage"`                  // IO usage in MB
+	Tickets                 int     `json:"tickets" xml:"tickets"`                   // Number of assigned tickets
+	OverrideTickets         int     `json:"overrideTickets" xml:"otickets"`          // Number of assigned override tickets
+	FairshareTickets        int     `json:"fairShareTickets" xml:"ftickets"`         // Number of assigned fairshare tickets
+	ShareTreeTickets        int     `json:"shareTreeTickets" xml:"stickets"`         // Number of assigned sharetree tickets
+	Share                   float64 `json:"share" xml:"JAT_share"`                   // The job's share of the tickets of all jobs, in the range between 0.0 and 1.0
+	QueueName               string  `json:"queueName" xml:"queue_name"`              // Queue in which the job is executing
+	Slots                   int     `json:"slots" xml:"slots"`                       // Number of slots
+	Tasks                   string  `json:"tasks" xml:"tasks"`                       // Task string
+}
+
+// MemUsageString returns the job's memory usage formatted with binary units, eg: 1.5 GiB*s
+func (j QueueJob) MemUsageString() string {
+	return util.FormatBytes(j.MemUsage*1024*1024) + "*s"
+}
+
+// taskIDRanges returns the ranges in the job's task string.
+// A job that is not an array job has an empty task string and the single task 1.
+func (j QueueJob) taskIDRanges() ([]TaskIDRange, error) {
+	if j.Tasks == "" {
+		return []TaskIDRange{{1, 1, 1}}, nil
+	}
+	return ParseTaskIDRanges(j.Tasks)
 }
 
 // NumTasks returns the number of tasks in a QueueJob
 func (j QueueJob) NumTasks() int {
-	IDRanges, err := ParseTaskIDRanges(j.Tasks)
+	IDRanges, err := j.taskIDRanges()
 	if err != nil {
 		// Assume any unparseable output is a job with just 1
 		return 1
@@ -271,59 +909,212 @@ func (j QueueJob) NumTasks() int {
 	return n
 }
 
+// IsArray returns true if the job list entry is for more than one task, that is if its NumTasks is above 1.
+// The running tasks of an array job are listed separately, so an entry for a single task such as 3 is not an array.
+func (j QueueJob) IsArray() bool {
+	return j.NumTasks() > 1
+}
+
+// TaskIDs returns the IDs of the job's tasks, in the order of the ranges in its task string.
+// A job that is not an array job has the single task 1. It returns nil if the task string can't be parsed.
+func (j QueueJob) TaskIDs() []int {
+	ranges, err := j.taskIDRanges()
+	if err != nil {
+		return nil
+	}
+	var ids []int
+	for _, r := range ranges {
+		ids = append(ids, r.Tasks()...)
+	}
+	return ids
+}
+
+// HasTask reports whether the task with the given ID is one of the job's tasks.
+// It returns false if the task string can't be parsed.
+func (j QueueJob) HasTask(id int) bool {
+	ranges, err := j.taskIDRanges()
+	if err != nil {
+		return false
+	}
+	for _, r := range ranges {
+		if r.Contains(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueInstance returns the queue part of the job's queue instance name, eg: interactive.q for
+// interactive.q@cluster.example.com. If the name has no host part the whole name is returned.
+// Pending jobs have no queue and return an empty string.
+func (j QueueJob) QueueInstance() string {
+	if i := strings.Index(j.QueueName, "@"); i >= 0 {
+		return j.QueueName[:i]
+	}
+	return j.QueueName
+}
+
+// Host returns the host part of the job's queue instance name, eg: cluster.example.com for
+// interactive.q@cluster.example.com. It returns an empty string if the name has no host part.
+func (j QueueJob) Host() string {
+	if i := strings.Index(j.QueueName, "@"); i >= 0 {
+		return j.QueueName[i+1:]
+	}
+	return ""
+}
+
+// queueTimeLayout is the layout of the timestamps in qstat -xml job lists
+const queueTimeLayout = "2006-01-02T15:04:05"
+
+// parseQueueTime parses a job list timestamp. qstat outputs them in the local time of the qmaster
+// without a zone, so they are interpreted in the local time zone.
+func parseQueueTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(queueTimeLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("qstat: invalid time %q: %s", s, err)
+	}
+	return t, nil
+}
+
+// StartTimeParsed returns the time the job started, in the local time zone.
+// Pending jobs have no start time and return the zero time.
+func (j QueueJob) StartTimeParsed() (time.Time, error) {
+	return parseQueueTime(j.StartTime)
+}
+
+// SubmissionTimeParsed returns the time the job was submitted, in the local time zone.
+// Running jobs have no submission time in a job list and return the zero time.
+func (j QueueJob) SubmissionTimeParsed() (time.Time, error) {
+	return parseQueueTime(j.SubmissionTime)
+}
+
+// WaitTime returns how long the job waited in the queue, from its submission until it started, or until now
+// if it is still pending. It is 0 if the submission time is not known, as for running jobs in a job list.
+func (j QueueJob) WaitTime(now time.Time) time.Duration {
+	submitted, err := j.SubmissionTimeParsed()
+	if err != nil || submitted.IsZero() {
+		return 0
+	}
+	end := now
+	if started, err := j.StartTimeParsed(); err == nil && !started.IsZero() {
+		end = started
+	}
+	if end.Before(submitted) {
+		return 0
+	}
+	return end.Sub(submitted)
+}
+
+// State is a job state string decoded in to its individual states.
+// See man 1 qstat for a description of the states.
+type State struct {
+	Deletion       bool `json:"deletion"`       // (d)eletion
+	Error          bool `json:"error"`          // (E)rror
+	Hold           bool `json:"hold"`           // (h)old
+	Running        bool `json:"running"`        // (r)unning
+	Restarted      bool `json:"restarted"`      // (R)estarted
+	Suspended      bool `json:"suspended"`      // (s)uspended
+	QueueSuspended bool `json:"queueSuspended"` // queue (S)uspended
+	Transferring   bool `json:"transferring"`   // (t)ransferring
+	Threshold      bool `json:"threshold"`      // (T)hreshold
+	Waiting        bool `json:"waiting"`        // (w)aiting
+	Queued         bool `json:"queued"`         // (q)ueued
+}
+
+// ParseState decodes a state string such as "Eqw" or "dr" as output by qstat.
+// Unknown letters are ignored.
+func ParseState(s string) State {
+	var st State
+	for _, c := range s {
+		switch c {
+		case 'd':
+			st.Deletion = true
+		case 'E':
+			st.Error = true
+		case 'h':
+			st.Hold = true
+		case 'r':
+			st.Running = true
+		case 'R':
+			st.Restarted = true
+		case 's':
+			st.Suspended = true
+		case 'S':
+			st.QueueSuspended = true
+		case 't':
+			st.Transferring = true
+		case 'T':
+			st.Threshold = true
+		case 'w':
+			st.Waiting = true
+		case 'q':
+			st.Queued = true
+		}
+	}
+	return st
+}
+
+// ParsedState returns the job's decoded state
+func (j QueueJob) ParsedState() State {
+	return ParseState(j.State)
+}
+
 // DeletionState returns true if the job is in the (d)eletion state
 func (j QueueJob) DeletionState() bool {
-	return strings.Contains(j.State, "d")
+	return j.ParsedState().Deletion
 }
 
 // ErrorState returns true if the job is in the (E)rror state
 func (j QueueJob) ErrorState() bool {
-	return strings.Contains(j.State, "E")
+	return j.ParsedState().Error
 }
 
 // HoldState returns true if the job is in the (h)old state
 func (j QueueJob) HoldState() bool {
-	return strings.Contains(j.State, "h")
+	return j.ParsedState().Hold
 }
 
 // RunningState returns true if the job is in the (r)unning state
 func (j QueueJob) RunningState() bool {
-	return strings.Contains(j.State, "r")
+	return j.ParsedState().Running
 }
 
 // RestartedState returns true if the job is in the (R)estarted state
 func (j QueueJob) RestartedState() bool {
-	return strings.Contains(j.State, "R")
+	return j.ParsedState().Restarted
 }
 
 // SuspendedState returns true if the job is in the (s)uspended state
 func (j QueueJob) SuspendedState() bool {
-	return strings.Contains(j.State, "s")
+	return j.ParsedState().Suspended
 }
 
 // QueueSuspendedState returns true if the job is in the queue (S)uspended state
 func (j QueueJob) QueueSuspendedState() bool {
-	return strings.Contains(j.State, "S")
+	return j.ParsedState().QueueSuspended
 }
 
 // TransferringState returns true if the job is in the (t)ransferring state
 func (j QueueJob) TransferringState() bool {
-	return strings.Contains(j.State, "t")
+	return j.ParsedState().Transferring
 }
 
 // ThresholdState returns true if the job is in the (T)hreshold state
 func (j QueueJob) ThresholdState() bool {
-	return strings.Contains(j.State, "T")
+	return j.ParsedState().Threshold
 }
 
 // WaitingState returns true if the job is in the (w)aiting state
 func (j QueueJob) WaitingState() bool {
-	return strings.Contains(j.State, "w")
+	return j.ParsedState().Waiting
 }
 
 // QueuedState returns true if the job is in the (q)ueued state
 func (j QueueJob) QueuedState() bool {
-	return strings.Contains(j.State, "q")
+	return j.ParsedState().Queued
 }
 
 // QueueResource describes a single consumable resource in a queue.
@@ -339,17 +1130,184 @@ type Queue struct {
 	SlotsUsed     int             `json:"slotsUsed" xml:"slots_used"`
 	SlotsReserved int             `json:"slotsReserved" xml:"slots_resv"`
 	SlotsTotal    int             `json:"slotsTotal" xml:"slots_total"`
+	LoadAvg       float64         `json:"loadAvg" xml:"load_avg"` // The load average of the queue instance's host
 	Arch          string          `json:"arch" xml:"arch"`
+	State         string          `json:"state" xml:"state"` // The queue instance state letters, eg: au
 	Joblist       []QueueJob      `json:"jobList" xml:"job_list"`
 	Resources     []QueueResource `json:"resources" xml:"resource"`
 }
 
+// QueueState is a queue instance state string decoded in to its individual states.
+// See man 1 qstat for a description of the states.
+type QueueState struct {
+	Alarm             bool `json:"alarm"`             // (a)larm, a load threshold is exceeded
+	SuspendAlarm      bool `json:"suspendAlarm"`      // suspend (A)larm, a suspend threshold is exceeded
+	Unknown           bool `json:"unknown"`           // (u)nknown, the execution daemon can't be contacted
+	Disabled          bool `json:"disabled"`          // (d)isabled by an administrator
+	CalendarDisabled  bool `json:"calendarDisabled"`  // (D)isabled by a calendar
+	Error             bool `json:"error"`             // (E)rror, a job failed to start in the queue
+	ConfigAmbiguous   bool `json:"configAmbiguous"`   // (c)onfiguration ambiguous
+	Suspended         bool `json:"suspended"`         // (s)uspended by an administrator
+	CalendarSuspended bool `json:"calendarSuspended"` // suspended by a (C)alendar
+	Subordinate       bool `json:"subordinate"`       // (S)ubordinate, suspended by another queue
+	Orphaned          bool `json:"orphaned"`          // (o)rphaned, removed from the configuration but still running jobs
+}
+
+// ParseQueueState decodes a queue instance state string such as "au" or "dE" as output by qstat -f.
+// Unknown letters are ignored.
+func ParseQueueState(s string) QueueState {
+	var st QueueState
+	for _, c := range s {
+		switch c {
+		case 'a':
+			st.Alarm = true
+		case 'A':
+			st.SuspendAlarm = true
+		case 'u':
+			st.Unknown = true
+		case 'd':
+			st.Disabled = true
+		case 'D':
+			st.CalendarDisabled = true
+		case 'E':
+			st.Error = true
+		case 'c':
+			st.ConfigAmbiguous = true
+		case 's':
+			st.Suspended = true
+		case 'C':
+			st.CalendarSuspended = true
+		case 'S':
+			st.Subordinate = true
+		case 'o':
+			st.Orphaned = true
+		}
+	}
+	return st
+}
+
+// ParsedState returns the queue instance's decoded state
+func (q Queue) ParsedState() QueueState {
+	return ParseQueueState(q.State)
+}
+
+// Available returns true if the queue instance can run new jobs, that is it is not disabled,
+// suspended, in error or unreachable.
+func (st QueueState) Available() bool {
+	return !(st.Unknown || st.Disabled || st.CalendarDisabled || st.Error || st.Suspended ||
+		st.CalendarSuspended || st.Subordinate || st.Orphaned)
+}
+
+// SlotsUsed returns the number of slots used by running jobs in all of the queues in qs
+func SlotsUsed(qs []Queue) int {
+	n := 0
+	for _, q := range qs {
+		n += q.SlotsUsed
+	}
+	return n
+}
+
+// SlotsReserved returns the number of slots reserved for pending jobs in all of the queues in qs
+func SlotsReserved(qs []Queue) int {
+	n := 0
+	for _, q := range qs {
+		n += q.SlotsReserved
+	}
+	return n
+}
+
+// SlotsTotal returns the total number of slots of all of the queues in qs
+func SlotsTotal(qs []Queue) int {
+	n := 0
+	for _, q := range qs {
+		n += q.SlotsTotal
+	}
+	return n
+}
+
+// SlotUtilization returns the fraction of the slots of the queues in qs that are occupied, in the range 0 to 1.
+// Reserved slots can't be used by other jobs, so they count as occupied along with the used slots.
+// It is 0 if the queues have no slots.
+func SlotUtilization(qs []Queue) float64 {
+	total := SlotsTotal(qs)
+	if total == 0 {
+		return 0
+	}
+	return float64(SlotsUsed(qs)+SlotsReserved(qs)) / float64(total)
+}
+
 type QueueInfo struct {
 	QueuedJobs  []QueueJob `json:"queuedJobs" xml:"queue_info>job_list"` // A list of jobs currently assigned to queues, eg: executing
 	PendingJobs []QueueJob `json:"pendingJobs" xml:"job_info>job_list"`  // A list of jobs that are not yet executing in any queue
 	Queues      []Queue    `json:"queues" xml:"queue_info>Queue-List"`   // A list of available queues (qstat -F)
 }
 
+// AllJobs returns the jobs assigned to queues followed by the pending jobs
+func (q QueueInfo) AllJobs() []QueueJob {
+	js := make([]QueueJob, 0, len(q.QueuedJobs)+len(q.PendingJobs))
+	js = append(js, q.QueuedJobs...)
+	return append(js, q.PendingJobs...)
+}
+
+// FindJob returns the first of the queued and then pending jobs with the given job number, and whether there is one.
+// The tasks of an array job share its job number, so the entry for any of its tasks may be returned.
+func (q QueueInfo) FindJob(jobNumber int) (QueueJob, bool) {
+	for _, js := range [][]QueueJob{q.QueuedJobs, q.PendingJobs} {
+		for _, j := range js {
+			if j.JobNumber == jobNumber {
+				return j, true
+			}
+		}
+	}
+	return QueueJob{}, false
+}
+
+// queueJobKey identifies an entry in a job list. Tasks of an array job running in different
+// queues are listed separately with the same job number, so the task string is part of the key.
+type queueJobKey struct {
+	jobNumber int
+	tasks     string
+}
+
+// mergeQueueJobs appends the jobs in js to dst, skipping any already present in seen.
+func mergeQueueJobs(dst []QueueJob, js []QueueJob, seen map[queueJobKey]bool) []QueueJob {
+	for _, j := range js {
+		k := queueJobKey{j.JobNumber, j.Tasks}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		dst = append(dst, j)
+	}
+	return dst
+}
+
+// MergeQueueInfo combines several QueueInfo results, such as those fetched for different users,
+// into one. The queued and pending job lists are concatenated in the order given, dropping
+// any job (or array task entry) that has already been seen. Queues are merged by name.
+// Nil elements of infos are ignored.
+func MergeQueueInfo(infos ...*QueueInfo) *QueueInfo {
+	merged := new(QueueInfo)
+	queued := make(map[queueJobKey]bool)
+	pending := make(map[queueJobKey]bool)
+	queues := make(map[string]bool)
+	for _, q := range infos {
+		if q == nil {
+			continue
+		}
+		merged.QueuedJobs = mergeQueueJobs(merged.QueuedJobs, q.QueuedJobs, queued)
+		merged.PendingJobs = mergeQueueJobs(merged.PendingJobs, q.PendingJobs, pending)
+		for _, queue := range q.Queues {
+			if queues[queue.Name] {
+				continue
+			}
+			queues[queue.Name] = true
+			merged.Queues = append(merged.Queues, queue)
+		}
+	}
+	return merged
+}
+
 // absPaths converts the paths of a list of PathList structs in to absolute paths of root if they are not already absolute.
 func absPaths(root string, ps []PathList) []PathList {
 	var paths []PathList
@@ -378,57 +1336,167 @@ func (i *JobInfo) StderrPaths() []PathList {
 	return paths
 }
 
-func (i *JobInfo) Command() string {
-	return i.ScriptFile + " " + strings.Join(i.JobArgs, " ")
+// StdoutPathsResolved is like StdoutPaths, but with the pseudo variables GridEngine substitutes in
+// output paths replaced, giving the files the output of the task with ID taskID is written to.
+// See ResolvePath for the variables replaced.
+func (i *JobInfo) StdoutPathsResolved(taskID int) []PathList {
+	var paths []PathList
+	paths = append(paths, absPaths(i.Cwd, i.resolvePaths(taskID, i.StdoutPathList))...)
+	paths = append(paths, absPaths(i.Cwd, i.resolvePaths(taskID, i.AltStdoutPathList))...)
+	return paths
 }
 
-// Qstat runs qstat -xml with the given arguments and decodes the xml in to result
-func Qstat(result interface{}, args ...string) error {
-	args = append([]string{"-xml"}, args...)
-	cmd := exec.Command("qstat", args...)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("qstat: could not get stdout: %s", err)
+// StderrPathsResolved is like StderrPaths, but with the pseudo variables replaced as for StdoutPathsResolved.
+func (i *JobInfo) StderrPathsResolved(taskID int) []PathList {
+	var paths []PathList
+	if !i.MergeStdErr {
+		paths = append(paths, absPaths(i.Cwd, i.resolvePaths(taskID, i.StderrPathList))...)
+		paths = append(paths, absPaths(i.Cwd, i.resolvePaths(taskID, i.AltStderrPathList))...)
 	}
-	if err = cmd.Start(); err != nil {
-		return fmt.Errorf("qstat: could not start qstat: %s", err)
+	return paths
+}
+
+// resolvePaths returns a copy of ps with the pseudo variables in each path replaced
+func (i *JobInfo) resolvePaths(taskID int, ps []PathList) []PathList {
+	var paths []PathList
+	for _, p := range ps {
+		p.Path = i.ResolvePath(p.Path, p.Host, taskID)
+		paths = append(paths, p)
 	}
-	defer cmd.Wait()
-	dec := xml.NewDecoder(util.NewValidUTF8Reader(stdout))
-	dec.Strict = false
-	if err = dec.Decode(result); err != nil {
-		return fmt.Errorf("qstat: could not decode output: %s", err)
+	return paths
+}
+
+// ResolvePath replaces the pseudo variables GridEngine substitutes in the output paths of the task
+// with ID taskID running on host. The variables are:
+//
+//		$HOME      the home directory of the job owner, from the job's environment
+//		$USER      the job owner
+//		$JOB_ID    the job number
+//		$JOB_NAME  the job name
+//		$HOSTNAME  host, left as is if host is empty
+//		$TASK_ID   taskID, or "undefined" if taskID is 0 as for a job that is not an array job
+//
+// $HOME is left as is if the job's environment does not include the home directory.
+func (i *JobInfo) ResolvePath(p, host string, taskID int) string {
+	task := "undefined"
+	if taskID > 0 {
+		task = strconv.Itoa(taskID)
 	}
-	return nil
+	vars := []string{
+		"$USER", i.Owner,
+		"$JOB_ID", strconv.Itoa(i.JobNumber),
+		"$JOB_NAME", i.JobName,
+		"$TASK_ID", task,
+	}
+	// qsub records the submitting environment with the __SGE_PREFIX__O_ prefix
+	if home, ok := i.Env("HOME"); ok {
+		vars = append(vars, "$HOME", home)
+	} else if home, ok := i.Env("__SGE_PREFIX__O_HOME"); ok {
+		vars = append(vars, "$HOME", home)
+	}
+	if host != "" {
+		vars = append(vars, "$HOSTNAME", host)
+	}
+	return strings.NewReplacer(vars...).Replace(p)
+}
+
+// CommandArgs returns the job's script followed by its arguments
+func (i *JobInfo) CommandArgs() []string {
+	return append([]string{i.ScriptFile}, i.JobArgs...)
+}
+
+// Command returns the job's script and arguments as a command line, with each word quoted
+// for a POSIX shell if needed so it can be copied and run.
+func (i *JobInfo) Command() string {
+	args := i.CommandArgs()
+	for k, a := range args {
+		args[k] = shellQuote(a)
+	}
+	return strings.Join(args, " ")
+}
+
+// Qstat runs qstat -xml with the given arguments and decodes the xml in to result.
+// If qstat fails the returned error includes what it printed to standard error.
+func Qstat(result interface{}, args ...string) error {
+	return QstatContext(context.Background(), result, args...)
+}
+
+// QstatContext is like Qstat, but qstat is killed if ctx is done before it exits.
+func QstatContext(ctx context.Context, result interface{}, args ...string) error {
+	return DefaultClient.qstat(ctx, result, args...)
+}
+
+// QstatRaw runs qstat -xml with the given arguments and returns the XML that Qstat would decode.
+// It is useful for seeing exactly what qstat produced when a field is unexpectedly empty.
+func QstatRaw(args ...string) ([]byte, error) {
+	return DefaultClient.QstatRaw(context.Background(), args...)
 }
 
 // GetDetailedJobInfo returns a DetailedJobInfo structure contianing all jobs matching the provided pattern.
 // The pattern should match the type wc_job_list as defined in man 1 sge_types
 func GetDetailedJobInfo(pattern string) (*DetailedJobInfo, error) {
-	q := new(DetailedJobInfo)
-	err := Qstat(q, "-j", pattern)
+	return GetDetailedJobInfoContext(context.Background(), pattern)
+}
+
+// GetDetailedJobInfoContext is like GetDetailedJobInfo, but qstat is killed if ctx is done before it exits.
+func GetDetailedJobInfoContext(ctx context.Context, pattern string) (*DetailedJobInfo, error) {
+	b, ws, err := DefaultClient.qstatRaw(ctx, "-j", pattern)
+	if unknownJobs(b, err) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, pattern)
+	}
 	if err != nil {
-		// Qstat just produces unparseable XML instead of doing real error reporting. Hurrah.
-		if strings.Contains(err.Error(), "XML syntax error on line 3: expected element name after <") {
-			return nil, fmt.Errorf("qstat: unknown job: %s", pattern)
+		return nil, err
+	}
+	q := new(DetailedJobInfo)
+	if err := DecodeQstat(bytes.NewReader(b), FormatXML, q); err != nil {
+		// What qstat printed to stderr may explain the output it could not produce
+		if len(ws) > 0 {
+			return nil, fmt.Errorf("qstat: could not get job info: %s: %s", err, strings.Join(ws, "; "))
 		}
 		return nil, err
 	}
 	return q, nil
 }
 
+// ErrUnknownJob is returned when no job matches the pattern given to GetDetailedJobInfo,
+// or qacct has no record of the job given to GetJobAccounting
+var ErrUnknownJob = errors.New("qstat: unknown job")
+
+// unknownJobs reports whether the output and error of qstat -j say that no job matched.
+// With -xml qstat outputs an unknown_jobs element, which isn't well formed, and without it
+// it prints that the jobs do not exist.
+func unknownJobs(output []byte, err error) bool {
+	var qerr *QstatError
+	if errors.As(err, &qerr) && strings.Contains(qerr.Stderr, "do not exist") {
+		return true
+	}
+	dec := xml.NewDecoder(bytes.NewReader(output))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local == "unknown_jobs"
+		}
+	}
+}
+
 // GetQueueInfo returns a QueueInfo reflecting the current state of the GridEngine queue.
 // The argument u can be used to limit the results to a particular user.
 // If u is the string "*" then results are returned for all users.
 // If u is the empty string then results are returned for the current user.
 func GetQueueInfo(u string) (*QueueInfo, error) {
-	if u == "" {
-		u = "*"
-	}
-	q := new(QueueInfo)
-	err := Qstat(q, "-pri", "-ext", "-urg", "-u", u)
-	if err != nil {
-		return nil, err
-	}
-	return q, nil
+	return GetQueueInfoContext(context.Background(), u)
+}
+
+// GetFullQueueInfo returns every queue instance along with the jobs running in it, as listed by qstat -f.
+func GetFullQueueInfo() ([]Queue, error) {
+	return DefaultClient.GetFullQueueInfo(context.Background())
+}
+
+// GetQueueInfoContext is like GetQueueInfo, but qstat is killed if ctx is done before it exits.
+func GetQueueInfoContext(ctx context.Context, u string) (*QueueInfo, error) {
+	return DefaultClient.queueInfo(ctx, u)
 }
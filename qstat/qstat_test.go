@@ -1,9 +1,14 @@
 package qstat
 
 import (
+	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 const queueInfo = `<?xml version='1.0'?>
@@ -88,30 +93,34 @@ func TestQueueInfo(t *testing.T) {
 
 	qj := r.QueuedJobs[0]
 	queuedExpected := QueueJob{
-		JobNumber:            3064076,
-		NormalizedPriority:   0.67712,
-		NormalizedUrgency:    0.00064,
-		NormalizedTickets:    1,
-		ResourceContribution: 512,
-		WaitTimeContribution: 15,
-		DeadlineContribution: 0,
-		POSIXPriority:        -500,
-		Name:                 "QRLOGIN",
-		Owner:                "bob",
-		Project:              "some_project",
-		Department:           "defaultdepartment",
-		State:                "r",
-		StartTime:            "2012-11-01T13:06:41",
-		SubmissionTime:       "",
-		CPUUsage:             0.0,
-		MemUsage:             0.0,
-		IOUsage:              0.0,
-		Tickets:              666,
-		OverrideTickets:      0,
-		FairshareTickets:     666,
-		ShareTreeTickets:     0,
-		QueueName:            "interactive.q@cluster",
-		Slots:                1,
+		JobNumber:               3064076,
+		NormalizedPriority:      0.67712,
+		NormalizedUrgency:       0.00064,
+		Urgency:                 527,
+		NormalizedTickets:       1,
+		NormalizedPriorityPosix: 0.25586,
+		ResourceContribution:    512,
+		WaitTimeContribution:    15,
+		DeadlineContribution:    0,
+		POSIXPriority:           -500,
+		Name:                    "QRLOGIN",
+		Owner:                   "bob",
+		Project:                 "some_project",
+		Department:              "defaultdepartment",
+		State:                   "r",
+		ListState:               "running",
+		StartTime:               "2012-11-01T13:06:41",
+		SubmissionTime:          "",
+		CPUUsage:                0.0,
+		MemUsage:                0.0,
+		IOUsage:                 0.0,
+		Tickets:                 666,
+		OverrideTickets:         0,
+		FairshareTickets:        666,
+		ShareTreeTickets:        0,
+		Share:                   0.16667,
+		QueueName:               "interactive.q@cluster",
+		Slots:                   1,
 	}
 	if !reflect.DeepEqual(qj, queuedExpected) {
 		t.Errorf("Queued job got %v, expected %v", qj, queuedExpected)
@@ -130,6 +139,7 @@ func TestQueueInfo(t *testing.T) {
 		JobNumber:            3050948,
 		NormalizedPriority:   0.70234,
 		NormalizedUrgency:    0.00064,
+		Urgency:              527,
 		NormalizedTickets:    1,
 		POSIXPriority:        -500,
 		ResourceContribution: 512,
@@ -140,6 +150,7 @@ func TestQueueInfo(t *testing.T) {
 		Project:              "some_other_project",
 		Department:           "defaultdepartment",
 		State:                "Eqw",
+		ListState:            "pending",
 		StartTime:            "",
 		SubmissionTime:       "2012-10-28T09:47:07",
 		CPUUsage:             0.0,
@@ -149,6 +160,7 @@ func TestQueueInfo(t *testing.T) {
 		OverrideTickets:      0,
 		FairshareTickets:     500,
 		ShareTreeTickets:     0,
+		Share:                0.125,
 		QueueName:            "",
 		Slots:                1,
 	}
@@ -179,6 +191,8 @@ func TestTaskIDRange(t *testing.T) {
 		{"6-8", TaskIDRange{6, 8, 1}, true},
 		{"1-10:3:4", TaskIDRange{}, false},
 		{"1--10", TaskIDRange{}, false},
+		{"1-10:0", TaskIDRange{}, false},
+		{"1-10:-2", TaskIDRange{}, false},
 	}
 
 	for i, test := range tests {
@@ -205,6 +219,8 @@ func TestNumTasks(t *testing.T) {
 		{TaskIDRange{1, 10, 2}, 5},
 		{TaskIDRange{1, 10, 3}, 4},
 		{TaskIDRange{6, 8, 1}, 3},
+		{TaskIDRange{1, 10, 0}, 0},
+		{TaskIDRange{1, 10, -1}, 0},
 	}
 
 	for i, test := range tests {
@@ -214,3 +230,1443 @@ func TestNumTasks(t *testing.T) {
 		}
 	}
 }
+
+// detailedJobInfo is qstat -j -xml output for a single job, with the value of
+// JB_restart left as a format verb.
+const detailedJobInfo = `<?xml version='1.0'?>
+<detailed_job_info  xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qstat/detailed_job_info.xsd?revision=1.11">
+  <djob_info>
+    <element>
+      <JB_job_number>3064080</JB_job_number>
+      <JB_ar>0</JB_ar>
+      <JB_exec_file>job_scripts/3064080</JB_exec_file>
+      <JB_submission_time>1351789601</JB_submission_time>
+      <JB_owner>bob</JB_owner>
+      <JB_uid>1000</JB_uid>
+      <JB_group>users</JB_group>
+      <JB_gid>100</JB_gid>
+      <JB_account>sge</JB_account>
+      <JB_merge_stderr>false</JB_merge_stderr>
+      <JB_notify>false</JB_notify>
+      <JB_job_name>render</JB_job_name>
+      <JB_jobshare>0</JB_jobshare>
+      <JB_script_file>render.sh</JB_script_file>
+      <JB_cwd>/home/bob</JB_cwd>
+      <JB_deadline>0</JB_deadline>
+      <JB_execution_time>0</JB_execution_time>
+      <JB_checkpoint_attr>0</JB_checkpoint_attr>
+      <JB_checkpoint_interval>0</JB_checkpoint_interval>
+      <JB_reserve>false</JB_reserve>
+      <JB_mail_options>0</JB_mail_options>
+      <JB_priority>1024</JB_priority>
+      <JB_restart>%d</JB_restart>
+      <JB_verify>false</JB_verify>
+      <JB_script_size>0</JB_script_size>
+      <JB_verify_suitable_queues>0</JB_verify_suitable_queues>
+      <JB_soft_wallclock_gmt>0</JB_soft_wallclock_gmt>
+      <JB_hard_wallclock_gmt>0</JB_hard_wallclock_gmt>
+      <JB_override_tickets>0</JB_override_tickets>
+      <JB_version>0</JB_version>
+      <JB_ja_structure>
+        <task_id_range>
+          <RN_min>1</RN_min>
+          <RN_max>1</RN_max>
+          <RN_step>1</RN_step>
+        </task_id_range>
+      </JB_ja_structure>
+      <JB_type>0</JB_type>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestRerunnable(t *testing.T) {
+	tests := []struct {
+		restart  int
+		expected RestartPolicy
+	}{
+		{0, RestartUnspecified},
+		{1, RestartYes},
+		{2, RestartNo},
+	}
+
+	for i, test := range tests {
+		var d DetailedJobInfo
+		if err := xml.Unmarshal([]byte(fmt.Sprintf(detailedJobInfo, test.restart)), &d); err != nil {
+			t.Fatalf("%d: Unmarshal failed: %s", i, err)
+		}
+		if len(d.Jobs) != 1 {
+			t.Fatalf("%d: Wrong number of jobs: %d", i, len(d.Jobs))
+		}
+		if p := d.Jobs[0].Rerunnable(); p != test.expected {
+			t.Errorf("%d: got %v, expected %v", i, p, test.expected)
+		}
+	}
+}
+
+func TestMergeQueueInfo(t *testing.T) {
+	bob := &QueueInfo{
+		QueuedJobs:  []QueueJob{{JobNumber: 1, Owner: "bob"}, {JobNumber: 2, Owner: "bob"}},
+		PendingJobs: []QueueJob{{JobNumber: 5, Owner: "bob"}},
+	}
+	john := &QueueInfo{
+		QueuedJobs:  []QueueJob{{JobNumber: 2, Owner: "bob"}, {JobNumber: 3, Owner: "john"}},
+		PendingJobs: []QueueJob{{JobNumber: 4, Owner: "john"}, {JobNumber: 5, Owner: "bob"}},
+	}
+
+	m := MergeQueueInfo(bob, nil, john)
+
+	if got, expected := jobNumbers(m.QueuedJobs), []int{1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Queued jobs got %v, expected %v", got, expected)
+	}
+	if got, expected := jobNumbers(m.PendingJobs), []int{5, 4}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Pending jobs got %v, expected %v", got, expected)
+	}
+
+	if m := MergeQueueInfo(); len(m.QueuedJobs) != 0 || len(m.PendingJobs) != 0 {
+		t.Errorf("Expected empty result merging nothing, got %v", m)
+	}
+}
+
+const shellListJobInfo = `<?xml version='1.0'?>
+<detailed_job_info  xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qstat/detailed_job_info.xsd?revision=1.11">
+  <djob_info>
+    <element>
+      <JB_job_number>3064081</JB_job_number>
+      <JB_owner>bob</JB_owner>
+      <JB_job_name>render</JB_job_name>
+      <JB_shell_list>
+        <path_list>
+          <PN_path>/bin/zsh</PN_path>
+          <PN_host>node01</PN_host>
+          <PN_file_host></PN_file_host>
+          <PN_file_staging>false</PN_file_staging>
+        </path_list>
+        <path_list>
+          <PN_path>/bin/bash</PN_path>
+          <PN_host></PN_host>
+          <PN_file_host></PN_file_host>
+          <PN_file_staging>false</PN_file_staging>
+        </path_list>
+      </JB_shell_list>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestShell(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(shellListJobInfo), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	if len(d.Jobs) != 1 {
+		t.Fatalf("Wrong number of jobs: %d", len(d.Jobs))
+	}
+	j := d.Jobs[0]
+	expected := []PathList{{Path: "/bin/zsh", Host: "node01"}, {Path: "/bin/bash"}}
+	if !reflect.DeepEqual(j.ShellList, expected) {
+		t.Errorf("Shell list got %v, expected %v", j.ShellList, expected)
+	}
+	if s := j.Shell(); s != "/bin/bash" {
+		t.Errorf("Shell got %q, expected %q", s, "/bin/bash")
+	}
+
+	tests := []struct {
+		shells   []PathList
+		expected string
+	}{
+		{nil, ""},
+		{[]PathList{{Path: "/bin/csh", Host: "node02"}}, "/bin/csh"},
+		{[]PathList{{Path: "/bin/sh"}}, "/bin/sh"},
+	}
+	for i, test := range tests {
+		if s := (JobInfo{ShellList: test.shells}).Shell(); s != test.expected {
+			t.Errorf("%d: got %q, expected %q", i, s, test.expected)
+		}
+	}
+}
+
+func TestTaskIDRangeString(t *testing.T) {
+	tests := []struct {
+		in       TaskIDRange
+		expected string
+	}{
+		{TaskIDRange{1, 1, 1}, "1"},
+		{TaskIDRange{1, 10, 1}, "1-10"},
+		{TaskIDRange{2, 8, 2}, "2-8:2"},
+	}
+	for i, test := range tests {
+		if s := test.in.String(); s != test.expected {
+			t.Errorf("%d: got %q, expected %q", i, s, test.expected)
+		}
+	}
+}
+
+func TestTaskIDRangeRoundTrip(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"", "1"},
+		{"5", "5"},
+		{"1-10", "1-10"},
+		{"1-10:1", "1-10"},
+		{"1-10:3", "1-10:3"},
+		{"7-7", "7"},
+		{"7-7:4", "7"},
+	}
+	for _, test := range tests {
+		r, err := NewTaskIDRange(test.in)
+		if err != nil {
+			t.Errorf("%q: NewTaskIDRange failed: %s", test.in, err)
+			continue
+		}
+		s := r.String()
+		if s != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.in, s, test.expected)
+		}
+		again, err := NewTaskIDRange(s)
+		if err != nil {
+			t.Errorf("%q: could not parse %q: %s", test.in, s, err)
+			continue
+		}
+		if again.Min != r.Min || again.Max != r.Max || again.NumTasks() != r.NumTasks() {
+			t.Errorf("%q: %+v did not round trip, got %+v", test.in, r, again)
+		}
+	}
+}
+
+const runningArrayJobInfo = `<?xml version='1.0'?>
+<detailed_job_info  xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qstat/detailed_job_info.xsd?revision=1.11">
+  <djob_info>
+    <element>
+      <JB_job_number>3064082</JB_job_number>
+      <JB_owner>bob</JB_owner>
+      <JB_job_name>sweep</JB_job_name>
+      <JB_ja_structure>
+        <task_id_range>
+          <RN_min>1</RN_min>
+          <RN_max>3</RN_max>
+          <RN_step>1</RN_step>
+        </task_id_range>
+      </JB_ja_structure>
+      <JB_ja_tasks>
+        <ulong_sublist>
+          <JAT_status>128</JAT_status>
+          <JAT_task_number>1</JAT_task_number>
+          <JAT_scaled_usage_list>
+            <scaled>
+              <UA_name>cpu</UA_name>
+              <UA_value>120.500000</UA_value>
+            </scaled>
+            <scaled>
+              <UA_name>mem</UA_name>
+              <UA_value>10.250000</UA_value>
+            </scaled>
+            <scaled>
+              <UA_name>io</UA_name>
+              <UA_value>0.010000</UA_value>
+            </scaled>
+            <scaled>
+              <UA_name>vmem</UA_name>
+              <UA_value>104857600.000000</UA_value>
+            </scaled>
+          </JAT_scaled_usage_list>
+        </ulong_sublist>
+        <ulong_sublist>
+          <JAT_status>128</JAT_status>
+          <JAT_task_number>2</JAT_task_number>
+          <JAT_scaled_usage_list>
+            <scaled>
+              <UA_name>cpu</UA_name>
+              <UA_value>3600.000000</UA_value>
+            </scaled>
+            <scaled>
+              <UA_name>mem</UA_name>
+              <UA_value>900.000000</UA_value>
+            </scaled>
+            <scaled>
+              <UA_name>io</UA_name>
+              <UA_value>2.500000</UA_value>
+            </scaled>
+            <scaled>
+              <UA_name>maxvmem</UA_name>
+              <UA_value>2147483648.000000</UA_value>
+            </scaled>
+          </JAT_scaled_usage_list>
+        </ulong_sublist>
+        <ulong_sublist>
+          <JAT_status>65536</JAT_status>
+          <JAT_task_number>3</JAT_task_number>
+        </ulong_sublist>
+      </JB_ja_tasks>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestBusiestTask(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(runningArrayJobInfo), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	j := d.Jobs[0]
+	n, u, ok := j.BusiestTask()
+	if !ok {
+		t.Fatalf("expected a busiest task")
+	}
+	if n != 2 {
+		t.Errorf("got task %d, expected 2", n)
+	}
+	expected := Usage{CPU: 3600, Mem: 900, IO: 2.5, MaxVMem: 2147483648}
+	if u != expected {
+		t.Errorf("got usage %+v, expected %+v", u, expected)
+	}
+
+	if _, ok := j.JobArrayTasks[2].Usage(); ok {
+		t.Errorf("expected no usage for a pending task")
+	}
+
+	single := JobInfo{JobArray: TaskIDRange{1, 1, 1}, JobArrayTasks: j.JobArrayTasks[:1]}
+	if _, _, ok := single.BusiestTask(); ok {
+		t.Errorf("expected no busiest task for a non-array job")
+	}
+	idle := JobInfo{JobArray: TaskIDRange{1, 3, 1}, JobArrayTasks: j.JobArrayTasks[2:]}
+	if _, _, ok := idle.BusiestTask(); ok {
+		t.Errorf("expected no busiest task for an array job without usage")
+	}
+}
+
+// Output of qstat -xml -j for a job that does not exist
+const unknownJobInfo = `<?xml version='1.0'?>
+<unknown_jobs  xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qstat/detailed_job_info.xsd?revision=1.11">
+  <>
+    <ST_name>1234</ST_name>
+  </>
+</unknown_jobs>
+`
+
+func TestGetDetailedJobInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		err    error
+		jobs   []int
+		errMsg string
+	}{
+		{"job", fmt.Sprintf(detailedJobInfo, 0), nil, []int{3064080}, ""},
+		{"unknown job", unknownJobInfo, nil, nil, "unknown job"},
+		{"unknown job with exit status", unknownJobInfo, &QstatError{ExitCode: 1, Args: []string{"qstat"}}, nil, "unknown job"},
+		{"unknown job on stderr", "", &QstatError{ExitCode: 1, Stderr: "Following jobs do not exist: \n3064080", Args: []string{"qstat"}}, nil, "unknown job"},
+		{"qstat failed", "", &QstatError{ExitCode: 1, Stderr: "error: commlib error", Args: []string{"qstat"}}, nil, "commlib error"},
+	}
+	for _, test := range tests {
+		r := &fakeRunner{output: []byte(test.output), err: test.err}
+		restore := useRunner(r)
+		d, err := GetDetailedJobInfo("3064080")
+		restore()
+
+		args := []string{"-xml", "-j", "3064080"}
+		if cmd := r.commands[0]; cmd.name != "qstat" || !reflect.DeepEqual(cmd.args, args) {
+			t.Errorf("%s: ran %s %q, expected qstat %q", test.name, cmd.name, cmd.args, args)
+		}
+		if test.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+				t.Errorf("%s: got error %v, expected %q", test.name, err, test.errMsg)
+			}
+			if unknown := errors.Is(err, ErrUnknownJob); unknown != strings.HasPrefix(test.name, "unknown job") {
+				t.Errorf("%s: errors.Is(err, ErrUnknownJob) is %v", test.name, unknown)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: GetDetailedJobInfo failed: %s", test.name, err)
+			continue
+		}
+		var jobs []int
+		for _, j := range d.Jobs {
+			jobs = append(jobs, j.JobNumber)
+		}
+		if !reflect.DeepEqual(jobs, test.jobs) {
+			t.Errorf("%s: got jobs %v, expected %v", test.name, jobs, test.jobs)
+		}
+	}
+}
+
+func TestGetDetailedJobInfoStderr(t *testing.T) {
+	r := &fakeRunner{output: []byte("<detailed_job_info>\n  <djob_info"), stderr: "error: job list truncated\n"}
+	defer useRunner(r)()
+	_, err := GetDetailedJobInfo("3064080")
+	if err == nil || !strings.Contains(err.Error(), "could not decode output") || !strings.HasSuffix(err.Error(), ": error: job list truncated") {
+		t.Errorf("got error %v, expected the decode error with qstat's stderr", err)
+	}
+}
+
+func TestGetQueueInfo(t *testing.T) {
+	tests := []struct {
+		user string
+		args []string
+	}{
+		{"", []string{"-xml", "-pri", "-ext", "-urg", "-u", "*"}},
+		{"*", []string{"-xml", "-pri", "-ext", "-urg", "-u", "*"}},
+		{"bob", []string{"-xml", "-pri", "-ext", "-urg", "-u", "bob"}},
+	}
+	for _, test := range tests {
+		r := &fakeRunner{output: []byte(queueInfo)}
+		restore := useRunner(r)
+		q, err := GetQueueInfo(test.user)
+		restore()
+		if err != nil {
+			t.Errorf("%q: GetQueueInfo failed: %s", test.user, err)
+			continue
+		}
+		if len(q.QueuedJobs) != 1 || len(q.PendingJobs) != 1 {
+			t.Errorf("%q: unexpected queue info: %+v", test.user, q)
+		}
+		if cmd := r.commands[0]; !reflect.DeepEqual(cmd.args, test.args) {
+			t.Errorf("%q: ran qstat %q, expected %q", test.user, cmd.args, test.args)
+		}
+	}
+}
+
+func TestQstat(t *testing.T) {
+	r := &fakeRunner{output: []byte(shellListJobInfo)}
+	defer useRunner(r)()
+	var d DetailedJobInfo
+	if err := Qstat(&d, "-j", "3064081"); err != nil {
+		t.Fatalf("Qstat failed: %s", err)
+	}
+	if len(d.Jobs) != 1 || d.Jobs[0].JobNumber != 3064081 {
+		t.Errorf("unexpected job info: %+v", d)
+	}
+
+	r.output = []byte("<detailed_job_info><")
+	if err := Qstat(&d, "-j", "3064081"); err == nil {
+		t.Errorf("expected error for truncated output")
+	}
+}
+
+func TestParseState(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected State
+	}{
+		{"", State{}},
+		{"r", State{Running: true}},
+		{"Rr", State{Restarted: true, Running: true}},
+		{"qw", State{Queued: true, Waiting: true}},
+		{"hqw", State{Hold: true, Queued: true, Waiting: true}},
+		{"Eqw", State{Error: true, Queued: true, Waiting: true}},
+		{"dr", State{Deletion: true, Running: true}},
+		{"t", State{Transferring: true}},
+		{"sS", State{Suspended: true, QueueSuspended: true}},
+		{"T", State{Threshold: true}},
+		{"rx", State{Running: true}},
+	}
+	for _, test := range tests {
+		if st := ParseState(test.s); st != test.expected {
+			t.Errorf("%q: got %+v, expected %+v", test.s, st, test.expected)
+		}
+	}
+
+	j := QueueJob{State: "Eqw"}
+	if !j.ErrorState() || !j.QueuedState() || !j.WaitingState() || j.RunningState() || j.HoldState() {
+		t.Errorf("%q: predicates disagree with ParsedState %+v", j.State, j.ParsedState())
+	}
+}
+
+func TestQueueJobTimes(t *testing.T) {
+	var q QueueInfo
+	if err := xml.Unmarshal([]byte(queueInfo), &q); err != nil {
+		t.Fatal(err)
+	}
+	running, pending := q.QueuedJobs[0], q.PendingJobs[0]
+
+	tests := []struct {
+		name     string
+		parse    func() (time.Time, error)
+		expected time.Time
+	}{
+		{"running start", running.StartTimeParsed, time.Date(2012, 11, 1, 13, 6, 41, 0, time.Local)},
+		{"running submission", running.SubmissionTimeParsed, time.Time{}},
+		{"pending start", pending.StartTimeParsed, time.Time{}},
+		{"pending submission", pending.SubmissionTimeParsed, time.Date(2012, 10, 28, 9, 47, 7, 0, time.Local)},
+	}
+	for _, test := range tests {
+		tm, err := test.parse()
+		if err != nil {
+			t.Errorf("%s: failed: %s", test.name, err)
+			continue
+		}
+		if !tm.Equal(test.expected) {
+			t.Errorf("%s: got %s, expected %s", test.name, tm, test.expected)
+		}
+	}
+
+	if _, err := (QueueJob{StartTime: "11/01/2012 13:06"}).StartTimeParsed(); err == nil {
+		t.Errorf("expected error for invalid start time")
+	}
+}
+
+func TestJobInfoTimes(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(fmt.Sprintf(detailedJobInfo, 0)), &d); err != nil {
+		t.Fatal(err)
+	}
+	i := d.Jobs[0]
+	if tm := i.SubmissionTimeParsed(); !tm.Equal(time.Date(2012, 11, 1, 17, 6, 41, 0, time.UTC)) {
+		t.Errorf("got submission time %s", tm.UTC())
+	}
+	if tm := i.ExecutionTimeParsed(); !tm.IsZero() {
+		t.Errorf("got execution time %s, expected the zero time", tm)
+	}
+	i.ExecutionTime = 1351800000
+	if tm := i.ExecutionTimeParsed(); tm.Unix() != 1351800000 {
+		t.Errorf("got execution time %d, expected 1351800000", tm.Unix())
+	}
+}
+
+func TestTaskStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected string
+		has      []TaskStatus
+	}{
+		{0, "idle", []TaskStatus{TaskIdle}},
+		{128, "running", []TaskStatus{TaskRunning}},
+		{512, "transferring", []TaskStatus{TaskTransferring}},
+		{4096, "exiting", []TaskStatus{TaskExiting}},
+		{65536, "finished", []TaskStatus{TaskFinished}},
+		{128 | 256, "running|suspended", []TaskStatus{TaskRunning, TaskSuspended}},
+		{128 | 0x100000, "running|0x100000", []TaskStatus{TaskRunning}},
+	}
+	for _, test := range tests {
+		task := Task{Status: test.status}
+		if s := task.StatusString(); s != test.expected {
+			t.Errorf("%d: got %q, expected %q", test.status, s, test.expected)
+		}
+		for _, s := range test.has {
+			if !task.HasStatus(s) {
+				t.Errorf("%d: expected status %#x", test.status, int(s))
+			}
+		}
+	}
+	if (Task{Status: 128}).HasStatus(TaskFinished) {
+		t.Errorf("running task has finished status")
+	}
+
+	// The statuses of a running array job as reported by qstat -j
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(runningArrayJobInfo), &d); err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, task := range d.Jobs[0].JobArrayTasks {
+		statuses = append(statuses, task.StatusString())
+	}
+	if expected := []string{"running", "running", "finished"}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("got %v, expected %v", statuses, expected)
+	}
+}
+
+// A hard resource list as output by qstat -xml -j for qsub -l h_rt=3600,h_vmem=2G,arch=lx*
+const hardResourceJobInfo = `<?xml version='1.0'?>
+<detailed_job_info>
+  <djob_info>
+    <element>
+      <JB_job_number>3064083</JB_job_number>
+      <JB_hard_resource_list>
+        <qstat_l_requests>
+          <CE_name>h_rt</CE_name>
+          <CE_valtype>3</CE_valtype>
+          <CE_stringval>3600</CE_stringval>
+          <CE_doubleval>3600.000000</CE_doubleval>
+          <CE_relop>5</CE_relop>
+          <CE_consumable>0</CE_consumable>
+          <CE_dominant>0</CE_dominant>
+          <CE_pj_doubleval>0.000000</CE_pj_doubleval>
+          <CE_pj_dominant>0</CE_pj_dominant>
+          <CE_requestable>0</CE_requestable>
+          <CE_tagged>0</CE_tagged>
+        </qstat_l_requests>
+        <qstat_l_requests>
+          <CE_name>h_vmem</CE_name>
+          <CE_valtype>4</CE_valtype>
+          <CE_stringval>2G</CE_stringval>
+          <CE_doubleval>2147483648.000000</CE_doubleval>
+          <CE_relop>5</CE_relop>
+          <CE_consumable>1</CE_consumable>
+          <CE_dominant>0</CE_dominant>
+          <CE_pj_doubleval>0.000000</CE_pj_doubleval>
+          <CE_pj_dominant>0</CE_pj_dominant>
+          <CE_requestable>0</CE_requestable>
+          <CE_tagged>0</CE_tagged>
+        </qstat_l_requests>
+        <qstat_l_requests>
+          <CE_name>arch</CE_name>
+          <CE_valtype>9</CE_valtype>
+          <CE_stringval>lx*</CE_stringval>
+          <CE_doubleval>0.000000</CE_doubleval>
+          <CE_relop>1</CE_relop>
+          <CE_consumable>0</CE_consumable>
+          <CE_dominant>0</CE_dominant>
+          <CE_pj_doubleval>0.000000</CE_pj_doubleval>
+          <CE_pj_dominant>0</CE_pj_dominant>
+          <CE_requestable>0</CE_requestable>
+          <CE_tagged>0</CE_tagged>
+        </qstat_l_requests>
+      </JB_hard_resource_list>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestResourceTypes(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(hardResourceJobInfo), &d); err != nil {
+		t.Fatal(err)
+	}
+	rs := d.Jobs[0].HardResourceRequest()
+	expected := []struct {
+		name    string
+		valType ValType
+		valName string
+		relOp   RelOp
+		opName  string
+	}{
+		{"h_rt", ValTypeTime, "TIME", RelOpLE, "<="},
+		{"h_vmem", ValTypeMemory, "MEMORY", RelOpLE, "<="},
+		{"arch", ValTypeRestrictedString, "RESTRING", RelOpEQ, "=="},
+	}
+	if len(rs) != len(expected) {
+		t.Fatalf("got %d resources, expected %d", len(rs), len(expected))
+	}
+	for i, e := range expected {
+		r := rs[i]
+		if r.Name != e.name || ValType(r.ValType) != e.valType || RelOp(r.RelOp) != e.relOp {
+			t.Errorf("%d: got %+v, expected %+v", i, r, e)
+		}
+		if r.ValTypeString() != e.valName || r.RelOpString() != e.opName {
+			t.Errorf("%s: got %s %s, expected %s %s", r.Name, r.ValTypeString(), r.RelOpString(), e.valName, e.opName)
+		}
+	}
+
+	b, err := json.Marshal(rs[1])
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	for k, v := range map[string]interface{}{"name": "h_vmem", "valType": 4.0, "relOp": 5.0, "valTypeName": "MEMORY", "relOpName": "<="} {
+		if fields[k] != v {
+			t.Errorf("JSON %s has %s %v, expected %v", b, k, fields[k], v)
+		}
+	}
+
+	if s := ValType(42).String(); s != "ValType(42)" {
+		t.Errorf("got %q for unknown value type", s)
+	}
+	if s := RelOp(0).String(); s != "RelOp(0)" {
+		t.Errorf("got %q for unknown relation operator", s)
+	}
+}
+
+func TestMailOptionsParsed(t *testing.T) {
+	tests := []struct {
+		mask     int
+		expected MailOptions
+	}{
+		{0, MailOptions{None: true}},
+		{NoMail, MailOptions{None: true}},
+		{MailAtAbort, MailOptions{Abort: true}},
+		{MailAtBeginning | MailAtExit, MailOptions{Begin: true, End: true}},
+		{MailAtBeginning | MailAtExit | MailAtAbort | MailAtSuspension, MailOptions{Begin: true, End: true, Abort: true, Suspend: true}},
+		{0x00140000, MailOptions{Abort: true, End: true}}, // -m ae
+	}
+	for _, test := range tests {
+		if m := (JobInfo{MailOptions: test.mask}).MailOptionsParsed(); m != test.expected {
+			t.Errorf("%#x: got %+v, expected %+v", test.mask, m, test.expected)
+		}
+	}
+}
+
+func TestTypeParsed(t *testing.T) {
+	tests := []struct {
+		name     string
+		mask     int
+		expected JobType
+	}{
+		{"batch script", 0x000, JobType{}},
+		{"array script", 0x100, JobType{Array: true}},
+		{"binary array", 0x180, JobType{Binary: true, Array: true}},
+		{"qlogin", 0x005, JobType{Immediate: true, Interactive: true}},
+		{"qrsh command", 0x089, JobType{Immediate: true, Interactive: true, Binary: true}},
+		{"qrsh -shell n", 0x289, JobType{Immediate: true, Interactive: true, Binary: true, NoShell: true}},
+		{"qrsh login", 0x021, JobType{Immediate: true, Interactive: true}},
+	}
+	for _, test := range tests {
+		i := JobInfo{Type: test.mask}
+		if jt := i.TypeParsed(); jt != test.expected {
+			t.Errorf("%s: got %+v, expected %+v", test.name, jt, test.expected)
+		}
+		if i.IsArray() != test.expected.Array || i.IsInteractive() != test.expected.Interactive {
+			t.Errorf("%s: IsArray %v, IsInteractive %v disagree with %+v", test.name, i.IsArray(), i.IsInteractive(), test.expected)
+		}
+	}
+}
+
+func TestQueueJobQueueInstance(t *testing.T) {
+	tests := []struct {
+		queueName string
+		queue     string
+		host      string
+	}{
+		{"interactive.q@cluster.example.com", "interactive.q", "cluster.example.com"},
+		{"", "", ""},
+		{"all.q", "all.q", ""},
+		{"all.q@", "all.q", ""},
+		{"@node01", "", "node01"},
+		{"all.q@node01@extra", "all.q", "node01@extra"},
+	}
+	for _, test := range tests {
+		j := QueueJob{QueueName: test.queueName}
+		if q, h := j.QueueInstance(), j.Host(); q != test.queue || h != test.host {
+			t.Errorf("%q: got %q, %q, expected %q, %q", test.queueName, q, h, test.queue, test.host)
+		}
+	}
+}
+
+const fullQueueInfo = `<?xml version='1.0'?>
+<job_info  xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qstat/qstat.xsd?revision=1.11">
+  <queue_info>
+    <Queue-List>
+      <name>all.q@node01</name>
+      <qtype>BIP</qtype>
+      <slots_used>2</slots_used>
+      <slots_resv>0</slots_resv>
+      <slots_total>8</slots_total>
+      <load_avg>1.52000</load_avg>
+      <arch>lx26-amd64</arch>
+      <job_list state="running">
+        <JB_job_number>3064076</JB_job_number>
+        <JAT_prio>0.67712</JAT_prio>
+        <JB_name>QRLOGIN</JB_name>
+        <JB_owner>bob</JB_owner>
+        <state>r</state>
+        <JAT_start_time>2012-11-01T13:06:41</JAT_start_time>
+        <slots>2</slots>
+      </job_list>
+    </Queue-List>
+    <Queue-List>
+      <name>all.q@node02</name>
+      <qtype>BIP</qtype>
+      <slots_used>0</slots_used>
+      <slots_resv>0</slots_resv>
+      <slots_total>8</slots_total>
+      <arch>lx26-amd64</arch>
+      <state>au</state>
+    </Queue-List>
+  </queue_info>
+  <job_info>
+    <job_list state="pending">
+      <JB_job_number>3050948</JB_job_number>
+      <JB_owner>john</JB_owner>
+      <state>qw</state>
+      <slots>1</slots>
+    </job_list>
+  </job_info>
+</job_info>
+`
+
+func TestGetFullQueueInfo(t *testing.T) {
+	r := &fakeRunner{output: []byte(fullQueueInfo)}
+	defer useRunner(r)()
+	qs, err := GetFullQueueInfo()
+	if err != nil {
+		t.Fatalf("GetFullQueueInfo failed: %s", err)
+	}
+	args := []string{"-xml", "-f", "-u", "*"}
+	if cmd := r.commands[0]; cmd.name != "qstat" || !reflect.DeepEqual(cmd.args, args) {
+		t.Errorf("ran %s %q, expected qstat %q", cmd.name, cmd.args, args)
+	}
+	if len(qs) != 2 {
+		t.Fatalf("got %d queues, expected 2", len(qs))
+	}
+
+	q := qs[0]
+	if q.Name != "all.q@node01" || q.QType != "BIP" || q.SlotsUsed != 2 || q.SlotsTotal != 8 || q.LoadAvg != 1.52 || q.Arch != "lx26-amd64" || q.State != "" {
+		t.Errorf("unexpected queue: %+v", q)
+	}
+	if len(q.Joblist) != 1 || q.Joblist[0].JobNumber != 3064076 || q.Joblist[0].Slots != 2 {
+		t.Errorf("unexpected job list: %+v", q.Joblist)
+	}
+	if q := qs[1]; q.State != "au" || q.LoadAvg != 0 || len(q.Joblist) != 0 {
+		t.Errorf("unexpected queue: %+v", q)
+	}
+}
+
+func TestParseQueueState(t *testing.T) {
+	tests := []struct {
+		s         string
+		expected  QueueState
+		available bool
+	}{
+		{"", QueueState{}, true},
+		{"a", QueueState{Alarm: true}, true},
+		{"au", QueueState{Alarm: true, Unknown: true}, false},
+		{"d", QueueState{Disabled: true}, false},
+		{"E", QueueState{Error: true}, false},
+		{"dE", QueueState{Disabled: true, Error: true}, false},
+		{"adu", QueueState{Alarm: true, Disabled: true, Unknown: true}, false},
+		{"c", QueueState{ConfigAmbiguous: true}, true},
+		{"S", QueueState{Subordinate: true}, false},
+		{"sA", QueueState{Suspended: true, SuspendAlarm: true}, false},
+		{"CD", QueueState{CalendarSuspended: true, CalendarDisabled: true}, false},
+		{"o", QueueState{Orphaned: true}, false},
+	}
+	for _, test := range tests {
+		st := (Queue{State: test.s}).ParsedState()
+		if st != test.expected {
+			t.Errorf("%q: got %+v, expected %+v", test.s, st, test.expected)
+		}
+		if st.Available() != test.available {
+			t.Errorf("%q: got available %v, expected %v", test.s, st.Available(), test.available)
+		}
+	}
+}
+
+func TestTaskIDRangeJSON(t *testing.T) {
+	tests := []struct {
+		r    TaskIDRange
+		json string
+	}{
+		{TaskIDRange{1, 1, 1}, `"1"`},
+		{TaskIDRange{5, 5, 1}, `"5"`},
+		{TaskIDRange{1, 10, 1}, `"1-10"`},
+		{TaskIDRange{1, 10, 3}, `"1-10:3"`},
+	}
+	for _, test := range tests {
+		b, err := json.Marshal(test.r)
+		if err != nil {
+			t.Errorf("%+v: Marshal failed: %s", test.r, err)
+			continue
+		}
+		if string(b) != test.json {
+			t.Errorf("%+v: got %s, expected %s", test.r, b, test.json)
+		}
+		var r TaskIDRange
+		if err := json.Unmarshal(b, &r); err != nil || r != test.r {
+			t.Errorf("%s: got %+v, %v, expected %+v", b, r, err, test.r)
+		}
+	}
+
+	decode := []struct {
+		json     string
+		expected TaskIDRange
+	}{
+		{`""`, TaskIDRange{1, 1, 1}},
+		{`{"min":2,"max":8,"step":2}`, TaskIDRange{2, 8, 2}},
+	}
+	for _, test := range decode {
+		var r TaskIDRange
+		if err := json.Unmarshal([]byte(test.json), &r); err != nil || r != test.expected {
+			t.Errorf("%s: got %+v, %v, expected %+v", test.json, r, err, test.expected)
+		}
+	}
+	for _, bad := range []string{`"1-x"`, `42`} {
+		var r TaskIDRange
+		if err := json.Unmarshal([]byte(bad), &r); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+
+	// Fields of type TaskIDRange use the range expression too
+	b, err := json.Marshal(SubmitOptions{Tasks: &TaskIDRange{1, 100, 1}})
+	if err != nil || !strings.Contains(string(b), `"tasks":"1-100"`) {
+		t.Errorf("got %s, %v", b, err)
+	}
+}
+
+func TestTaskIDRangeTasks(t *testing.T) {
+	tests := []struct {
+		r        TaskIDRange
+		expected []int
+	}{
+		{TaskIDRange{1, 10, 3}, []int{1, 4, 7, 10}},
+		{TaskIDRange{1, 9, 3}, []int{1, 4, 7}},
+		{TaskIDRange{5, 5, 1}, []int{5}},
+		{TaskIDRange{2, 6, 1}, []int{2, 3, 4, 5, 6}},
+		{TaskIDRange{1, 10, 0}, nil},
+		{TaskIDRange{10, 1, 1}, nil},
+	}
+	for _, test := range tests {
+		if ids := test.r.Tasks(); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%+v: got %v, expected %v", test.r, ids, test.expected)
+		}
+	}
+
+	jobs := []struct {
+		tasks    string
+		expected []int
+	}{
+		{"", []int{1}},
+		{"1-10:3", []int{1, 4, 7, 10}},
+		{"1-3:1,8,10-12:2", []int{1, 2, 3, 8, 10, 12}},
+		{"bogus", nil},
+	}
+	for _, test := range jobs {
+		if ids := (QueueJob{Tasks: test.tasks}).TaskIDs(); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%q: got %v, expected %v", test.tasks, ids, test.expected)
+		}
+	}
+}
+
+func TestTaskIDRangeContains(t *testing.T) {
+	tests := []struct {
+		r        TaskIDRange
+		id       int
+		expected bool
+	}{
+		{TaskIDRange{1, 10, 3}, 1, true},
+		{TaskIDRange{1, 10, 3}, 4, true},
+		{TaskIDRange{1, 10, 3}, 5, false},
+		{TaskIDRange{1, 10, 3}, 10, true},
+		{TaskIDRange{1, 9, 3}, 9, false},
+		{TaskIDRange{1, 10, 3}, 0, false},
+		{TaskIDRange{1, 10, 3}, 13, false},
+		{TaskIDRange{5, 5, 1}, 5, true},
+		{TaskIDRange{1, 10, 0}, 1, false},
+	}
+	for _, test := range tests {
+		if c := test.r.Contains(test.id); c != test.expected {
+			t.Errorf("%+v contains %d: got %t, expected %t", test.r, test.id, c, test.expected)
+		}
+	}
+
+	j := QueueJob{Tasks: "1-3:1,8,10-20:5"}
+	for id, expected := range map[int]bool{1: true, 3: true, 4: false, 8: true, 10: true, 12: false, 20: true, 21: false} {
+		if c := j.HasTask(id); c != expected {
+			t.Errorf("%q has task %d: got %t, expected %t", j.Tasks, id, c, expected)
+		}
+	}
+	if !(QueueJob{}).HasTask(1) {
+		t.Errorf("a job that is not an array job does not have task 1")
+	}
+	if (QueueJob{Tasks: "bogus"}).HasTask(1) {
+		t.Errorf("an unparseable task string has task 1")
+	}
+}
+
+func TestParseTaskIDRanges(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected []TaskIDRange
+	}{
+		{"", []TaskIDRange{}},
+		{"1,2,3", []TaskIDRange{{1, 1, 1}, {2, 2, 1}, {3, 3, 1}}},
+		{"1-4,7-9:2", []TaskIDRange{{1, 4, 1}, {7, 9, 2}}},
+	}
+	for _, test := range tests {
+		ranges, err := ParseTaskIDRanges(test.in)
+		if err != nil {
+			t.Errorf("%q: ParseTaskIDRanges failed: %s", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(ranges, test.expected) {
+			t.Errorf("%q: got %v, expected %v", test.in, ranges, test.expected)
+		}
+	}
+}
+
+func TestJobInfoEnv(t *testing.T) {
+	i := JobInfo{EnvList: []EnvVar{
+		{"PATH", "/usr/bin:/bin"},
+		{"SCRATCH", "/tmp/bob"},
+		{"EMPTY", ""},
+		{"SCRATCH", "/scratch/bob"},
+	}}
+	expected := map[string]string{"PATH": "/usr/bin:/bin", "SCRATCH": "/scratch/bob", "EMPTY": ""}
+	if env := i.EnvMap(); !reflect.DeepEqual(env, expected) {
+		t.Errorf("got %v, expected %v", env, expected)
+	}
+	for name, value := range expected {
+		if v, ok := i.Env(name); !ok || v != value {
+			t.Errorf("%s: got %q, %t, expected %q", name, v, ok, value)
+		}
+	}
+	if v, ok := i.Env("HOME"); ok {
+		t.Errorf("HOME: got %q, expected it to be unset", v)
+	}
+}
+
+func TestJobInfoResource(t *testing.T) {
+	i := JobInfo{
+		QstatHardResourceList:   []Resource{{Name: "h_rt", StringVal: "3600"}},
+		ElementHardResourceList: []Resource{{Name: "mem_free", StringVal: "4G"}},
+	}
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"h_rt", "3600"},
+		{"mem_free", "4G"},
+		{"MEM_FREE", "4G"},
+	}
+	for _, test := range tests {
+		r, ok := i.Resource(test.name)
+		if !ok || r.StringVal != test.value {
+			t.Errorf("%s: got %+v, %t, expected %s", test.name, r, ok, test.value)
+		}
+	}
+	if r, ok := i.Resource("h_vmem"); ok {
+		t.Errorf("h_vmem: got %+v, expected no request", r)
+	}
+}
+
+func TestStdoutPathsResolved(t *testing.T) {
+	i := &JobInfo{
+		JobNumber: 3064080,
+		JobName:   "render",
+		Owner:     "bob",
+		Cwd:       "/scratch/bob",
+		EnvList:   []EnvVar{{"__SGE_PREFIX__O_HOME", "/home/bob"}},
+		StdoutPathList: []PathList{
+			{Path: "$HOME/logs/$JOB_NAME.o$JOB_ID.$TASK_ID"},
+			{Path: "/var/log/$USER/$HOSTNAME.out", Host: "node01"},
+			{Path: "$JOB_ID.out"},
+		},
+		StderrPathList: []PathList{{Path: "/tmp/$USER/$HOSTNAME.err"}},
+	}
+
+	expected := []string{
+		"/home/bob/logs/render.o3064080.7",
+		"/var/log/bob/node01.out",
+		"/scratch/bob/3064080.out",
+	}
+	paths := i.StdoutPathsResolved(7)
+	if len(paths) != len(expected) {
+		t.Fatalf("got %d paths, expected %d", len(paths), len(expected))
+	}
+	for k, p := range paths {
+		if p.Path != expected[k] {
+			t.Errorf("%d: got %q, expected %q", k, p.Path, expected[k])
+		}
+	}
+	if p := i.StdoutPathsResolved(0)[0].Path; p != "/home/bob/logs/render.o3064080.undefined" {
+		t.Errorf("got %q for a job that is not an array job", p)
+	}
+	if p := i.StderrPathsResolved(7)[0].Path; p != "/tmp/bob/$HOSTNAME.err" {
+		t.Errorf("got %q, expected $HOSTNAME to be left for a path without a host", p)
+	}
+	if p := i.StdoutPathList[0].Path; p != "$HOME/logs/$JOB_NAME.o$JOB_ID.$TASK_ID" {
+		t.Errorf("resolving modified the job's path list: %q", p)
+	}
+
+	i.MergeStdErr = true
+	if paths := i.StderrPathsResolved(7); len(paths) != 0 {
+		t.Errorf("got %v, expected no stderr paths for a job merging stderr", paths)
+	}
+}
+
+func TestJobInfoCommand(t *testing.T) {
+	tests := []struct {
+		script   string
+		args     []string
+		expected string
+	}{
+		{"render.sh", nil, "render.sh"},
+		{"render.sh", []string{"-f", "1-10"}, "render.sh -f 1-10"},
+		{"render.sh", []string{"my scene.blend"}, "render.sh 'my scene.blend'"},
+		{"render.sh", []string{"bob's"}, `render.sh 'bob'\''s'`},
+		{"/home/bob/my jobs/run.sh", []string{""}, "'/home/bob/my jobs/run.sh' ''"},
+	}
+	for _, test := range tests {
+		i := &JobInfo{ScriptFile: test.script, JobArgs: test.args}
+		if c := i.Command(); c != test.expected {
+			t.Errorf("%q %q: got %s, expected %s", test.script, test.args, c, test.expected)
+		}
+		if args := i.CommandArgs(); !reflect.DeepEqual(args, append([]string{test.script}, test.args...)) {
+			t.Errorf("%q %q: got args %q", test.script, test.args, args)
+		}
+	}
+}
+
+const startedArrayJobInfo = `<?xml version='1.0'?>
+<detailed_job_info  xmlns:xsd="http://gridengine.sunsource.net/source/browse/*checkout*/gridengine/source/dist/util/resources/schemas/qstat/detailed_job_info.xsd?revision=1.11">
+  <djob_info>
+    <element>
+      <JB_job_number>3064083</JB_job_number>
+      <JB_owner>bob</JB_owner>
+      <JB_job_name>sweep</JB_job_name>
+      <JB_ja_structure>
+        <task_id_range>
+          <RN_min>1</RN_min>
+          <RN_max>3</RN_max>
+          <RN_step>1</RN_step>
+        </task_id_range>
+      </JB_ja_structure>
+      <JB_ja_tasks>
+        <ulong_sublist>
+          <JAT_status>128</JAT_status>
+          <JAT_task_number>1</JAT_task_number>
+          <JAT_start_time>1351789700</JAT_start_time>
+        </ulong_sublist>
+        <ulong_sublist>
+          <JAT_status>128</JAT_status>
+          <JAT_task_number>2</JAT_task_number>
+          <JAT_start_time>1351789610</JAT_start_time>
+        </ulong_sublist>
+        <ulong_sublist>
+          <JAT_status>65536</JAT_status>
+          <JAT_task_number>3</JAT_task_number>
+        </ulong_sublist>
+      </JB_ja_tasks>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestJobDurations(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(startedArrayJobInfo), &d); err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	now := time.Unix(1351789610+3600, 0)
+	if r := d.Jobs[0].Runtime(now); r != time.Hour {
+		t.Errorf("got runtime %s, expected %s", r, time.Hour)
+	}
+	if r := (JobInfo{}).Runtime(now); r != 0 {
+		t.Errorf("got runtime %s for a job that has not started", r)
+	}
+
+	submitted := time.Date(2012, 11, 1, 12, 0, 0, 0, time.Local)
+	now = submitted.Add(2 * time.Hour)
+	tests := []struct {
+		job      QueueJob
+		expected time.Duration
+	}{
+		{QueueJob{SubmissionTime: "2012-11-01T12:00:00", StartTime: "2012-11-01T12:30:00"}, 30 * time.Minute},
+		{QueueJob{SubmissionTime: "2012-11-01T12:00:00"}, 2 * time.Hour},
+		{QueueJob{StartTime: "2012-11-01T12:30:00"}, 0},
+		{QueueJob{SubmissionTime: "bogus"}, 0},
+	}
+	for i, test := range tests {
+		if w := test.job.WaitTime(now); w != test.expected {
+			t.Errorf("%d: got wait time %s, expected %s", i, w, test.expected)
+		}
+	}
+}
+
+func TestMemUsageString(t *testing.T) {
+	tests := []struct {
+		mem      float64
+		expected string
+	}{
+		{0, "0 B*s"},
+		{512, "512.0 MiB*s"},
+		{1023, "1023.0 MiB*s"},
+		{1024, "1.0 GiB*s"},
+		{1536, "1.5 GiB*s"},
+	}
+	for _, test := range tests {
+		if s := (QueueJob{MemUsage: test.mem}).MemUsageString(); s != test.expected {
+			t.Errorf("%v: got %q, expected %q", test.mem, s, test.expected)
+		}
+	}
+}
+
+func TestSlots(t *testing.T) {
+	qs := []Queue{
+		{Name: "all.q@node01", SlotsUsed: 6, SlotsReserved: 2, SlotsTotal: 8},
+		{Name: "all.q@node02", SlotsUsed: 1, SlotsReserved: 0, SlotsTotal: 8},
+		{Name: "gpu.q@node03", SlotsUsed: 0, SlotsReserved: 3, SlotsTotal: 4},
+	}
+	if n := SlotsUsed(qs); n != 7 {
+		t.Errorf("got %d slots used, expected 7", n)
+	}
+	if n := SlotsReserved(qs); n != 5 {
+		t.Errorf("got %d slots reserved, expected 5", n)
+	}
+	if n := SlotsTotal(qs); n != 20 {
+		t.Errorf("got %d slots in total, expected 20", n)
+	}
+	if u := SlotUtilization(qs); u != 0.6 {
+		t.Errorf("got utilization %v, expected 0.6", u)
+	}
+	if u := SlotUtilization(nil); u != 0 {
+		t.Errorf("got utilization %v for no queues, expected 0", u)
+	}
+}
+
+func TestHardResourceRequestDuplicates(t *testing.T) {
+	i := JobInfo{
+		QstatHardResourceList: []Resource{
+			{Name: "h_rt", StringVal: "3600"},
+			{Name: "h_vmem", ValType: 4, StringVal: "2G", DoubleVal: 2147483648, RelOp: 5},
+		},
+		ElementHardResourceList: []Resource{
+			{Name: "h_rt", ValType: 3, StringVal: "3600", DoubleVal: 3600, RelOp: 5},
+			{Name: "H_VMEM", StringVal: "2G"},
+			{Name: "mem_free", StringVal: "4G"},
+		},
+	}
+	expected := []Resource{
+		{Name: "h_rt", ValType: 3, StringVal: "3600", DoubleVal: 3600, RelOp: 5},
+		{Name: "h_vmem", ValType: 4, StringVal: "2G", DoubleVal: 2147483648, RelOp: 5},
+		{Name: "mem_free", StringVal: "4G"},
+	}
+	if rs := i.HardResourceRequest(); !reflect.DeepEqual(rs, expected) {
+		t.Errorf("got %+v, expected %+v", rs, expected)
+	}
+	if rs := (JobInfo{}).HardResourceRequest(); rs == nil || len(rs) != 0 {
+		t.Errorf("got %#v, expected an empty list", rs)
+	}
+}
+
+// softResourceJobInfo is qstat -j -xml output for a job submitted with qsub -l h_rt=3600 -soft -l arch=lx-amd64,gpu=1
+const softResourceJobInfo = `<?xml version='1.0'?>
+<detailed_job_info>
+  <djob_info>
+    <element>
+      <JB_job_number>3064084</JB_job_number>
+      <JB_hard_resource_list>
+        <qstat_l_requests>
+          <CE_name>h_rt</CE_name>
+          <CE_valtype>3</CE_valtype>
+          <CE_stringval>3600</CE_stringval>
+          <CE_doubleval>3600.000000</CE_doubleval>
+          <CE_relop>5</CE_relop>
+        </qstat_l_requests>
+      </JB_hard_resource_list>
+      <JB_soft_resource_list>
+        <qstat_l_requests>
+          <CE_name>arch</CE_name>
+          <CE_valtype>9</CE_valtype>
+          <CE_stringval>lx-amd64</CE_stringval>
+          <CE_doubleval>0.000000</CE_doubleval>
+          <CE_relop>1</CE_relop>
+        </qstat_l_requests>
+        <element>
+          <CE_name>gpu</CE_name>
+          <CE_valtype>1</CE_valtype>
+          <CE_stringval>1</CE_stringval>
+          <CE_doubleval>1.000000</CE_doubleval>
+          <CE_relop>5</CE_relop>
+          <CE_consumable>1</CE_consumable>
+        </element>
+      </JB_soft_resource_list>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestSoftResourceRequest(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(softResourceJobInfo), &d); err != nil {
+		t.Fatal(err)
+	}
+	j := d.Jobs[0]
+	var names []string
+	for _, r := range j.SoftResourceRequest() {
+		names = append(names, r.Name+"="+r.StringVal)
+	}
+	if expected := []string{"arch=lx-amd64", "gpu=1"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("got soft requests %q, expected %q", names, expected)
+	}
+	if hard := j.HardResourceRequest(); len(hard) != 1 || hard[0].Name != "h_rt" {
+		t.Errorf("got hard requests %+v, expected only h_rt", hard)
+	}
+}
+
+// queueListJobInfo is qstat -j -xml output for a job submitted with qsub -q all.q,gpu.q@node0* -soft -q fast.q
+const queueListJobInfo = `<?xml version='1.0'?>
+<detailed_job_info>
+  <djob_info>
+    <element>
+      <JB_job_number>3064085</JB_job_number>
+      <JB_hard_queue_list>
+        <destin_ident_list>
+          <QR_name>all.q</QR_name>
+        </destin_ident_list>
+        <destin_ident_list>
+          <QR_name>gpu.q@node0*</QR_name>
+        </destin_ident_list>
+      </JB_hard_queue_list>
+      <JB_soft_queue_list>
+        <destin_ident_list>
+          <QR_name>fast.q</QR_name>
+        </destin_ident_list>
+      </JB_soft_queue_list>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestQueueLists(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(queueListJobInfo), &d); err != nil {
+		t.Fatal(err)
+	}
+	j := d.Jobs[0]
+	if expected := []string{"all.q", "gpu.q@node0*"}; !reflect.DeepEqual(j.HardQueueList, expected) {
+		t.Errorf("got hard queues %q, expected %q", j.HardQueueList, expected)
+	}
+	if expected := []string{"fast.q"}; !reflect.DeepEqual(j.SoftQueueList, expected) {
+		t.Errorf("got soft queues %q, expected %q", j.SoftQueueList, expected)
+	}
+
+	allowed := map[string]bool{
+		"all.q":         true,
+		"all.q@node12":  true,
+		"gpu.q@node01":  true,
+		"gpu.q@node12":  false,
+		"gpu.q":         false,
+		"fast.q@node01": false,
+		"interactive.q": false,
+	}
+	for q, expected := range allowed {
+		if ok, err := j.QueueAllowed(q); ok != expected || err != nil {
+			t.Errorf("%s: got allowed %t (%v), expected %t", q, ok, err, expected)
+		}
+	}
+	if ok, _ := j.QueuePreferred("fast.q@node01"); !ok {
+		t.Errorf("soft queue request not matched")
+	}
+	if ok, _ := j.QueuePreferred("all.q@node01"); ok {
+		t.Errorf("soft queue request matched another queue")
+	}
+	if ok, _ := (JobInfo{}).QueueAllowed("any.q@node01"); !ok {
+		t.Errorf("a job without queue requests should be allowed in any queue")
+	}
+	if ok, _ := (JobInfo{}).QueuePreferred("any.q"); ok {
+		t.Errorf("a job without queue requests should prefer no queue")
+	}
+}
+
+func TestMatchQueues(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		queue    string
+		expected bool
+		err      bool
+	}{
+		{[]string{"*@node0[1-3]"}, "all.q@node02", true, false},
+		{[]string{"*@node0[!1-3]"}, "all.q@node02", false, false},
+		{[]string{"@node01"}, "gpu.q@node01", true, false},
+		{[]string{"@node01"}, "gpu.q@node02", false, false},
+		{[]string{"*.q"}, "proj/a.q", true, false},
+		{[]string{"all.q@*"}, "all.q@rack1/node01", true, false},
+		{[]string{"all.q@*"}, "all.q", false, false},
+		{[]string{"all.q@@gpu"}, "all.q@node01", false, true},
+		{[]string{"all.q@@gpu"}, "gpu.q@node01", false, false},
+		{[]string{"all.q@@gpu"}, "all.q", false, false},
+		{[]string{"all.q@@gpu", "all.q@node0*"}, "all.q@node01", true, false},
+		{[]string{"all.q@node[01"}, "all.q@node01", false, true},
+	}
+	for _, test := range tests {
+		ok, err := matchQueues(test.patterns, test.queue)
+		if ok != test.expected || (err != nil) != test.err {
+			t.Errorf("%q, %s: got %t (%v), expected %t", test.patterns, test.queue, ok, err, test.expected)
+		}
+	}
+}
+
+// parallelJobInfo is qstat -j -xml output for a job submitted with qsub -pe mpi 4-8
+const parallelJobInfo = `<?xml version='1.0'?>
+<detailed_job_info>
+  <djob_info>
+    <element>
+      <JB_job_number>3064086</JB_job_number>
+      <JB_pe>mpi</JB_pe>
+      <JB_pe_range>
+        <ranges>
+          <RN_min>4</RN_min>
+          <RN_max>8</RN_max>
+          <RN_step>1</RN_step>
+        </ranges>
+      </JB_pe_range>
+    </element>
+  </djob_info>
+</detailed_job_info>
+`
+
+func TestParallelEnv(t *testing.T) {
+	var d DetailedJobInfo
+	if err := xml.Unmarshal([]byte(parallelJobInfo), &d); err != nil {
+		t.Fatal(err)
+	}
+	j := d.Jobs[0]
+	if !j.IsParallel() || j.ParallelEnv != "mpi" {
+		t.Errorf("got parallel environment %q, expected mpi", j.ParallelEnv)
+	}
+	if expected := (TaskIDRange{4, 8, 1}); j.PERange != expected {
+		t.Errorf("got slot range %v, expected %v", j.PERange, expected)
+	}
+	if (JobInfo{}).IsParallel() {
+		t.Errorf("a serial job is parallel")
+	}
+}
+
+func TestIsArray(t *testing.T) {
+	jobs := []struct {
+		job      JobInfo
+		expected bool
+	}{
+		{JobInfo{JobArray: TaskIDRange{1, 1, 1}}, false},
+		{JobInfo{JobArray: TaskIDRange{1, 10, 1}}, true},
+		{JobInfo{JobArray: TaskIDRange{1, 10, 3}}, true},
+		{JobInfo{JobArray: TaskIDRange{1, 1, 1}, Type: JobTypeArray}, true},
+	}
+	for _, test := range jobs {
+		if a := test.job.IsArray(); a != test.expected {
+			t.Errorf("%v type %#x: got %t, expected %t", test.job.JobArray, test.job.Type, a, test.expected)
+		}
+		if test.job.NumTasks() > 1 && !test.job.IsArray() {
+			t.Errorf("%v: job with %d tasks is not an array job", test.job.JobArray, test.job.NumTasks())
+		}
+	}
+
+	queueJobs := []struct {
+		tasks    string
+		expected bool
+	}{
+		{"", false},
+		{"3", false},
+		{"1-4", true},
+		{"1-10:1", true},
+		{"1-3:1,8", true},
+		{"bogus", false},
+	}
+	for _, test := range queueJobs {
+		j := QueueJob{Tasks: test.tasks}
+		if a := j.IsArray(); a != test.expected {
+			t.Errorf("%q: got %t, expected %t", test.tasks, a, test.expected)
+		}
+		if j.NumTasks() > 1 && !j.IsArray() {
+			t.Errorf("%q: job with %d tasks is not an array job", test.tasks, j.NumTasks())
+		}
+	}
+}
+
+func TestQueueInfoJobs(t *testing.T) {
+	var q QueueInfo
+	if err := xml.Unmarshal([]byte(queueInfo), &q); err != nil {
+		t.Fatal(err)
+	}
+	if ns := jobNumbers(q.AllJobs()); !reflect.DeepEqual(ns, []int{3064076, 3050948}) {
+		t.Errorf("got jobs %v, expected [3064076 3050948]", ns)
+	}
+	for _, n := range []int{3064076, 3050948} {
+		if j, ok := q.FindJob(n); !ok || j.JobNumber != n {
+			t.Errorf("%d: got %d, %t", n, j.JobNumber, ok)
+		}
+	}
+	if j, ok := q.FindJob(1); ok {
+		t.Errorf("found job %d, expected none", j.JobNumber)
+	}
+	if js := new(QueueInfo).AllJobs(); len(js) != 0 {
+		t.Errorf("got %v, expected no jobs", js)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SubmitOptions are the qsub options of a job submission.
+// See man 1 qsub for a description of the options.
+type SubmitOptions struct {
+	Name      string            `json:"name"`      // The job name (-N)
+	Queue     string            `json:"queue"`     // The queue or queue instance to run in (-q)
+	PE        string            `json:"pe"`        // The parallel environment (-pe)
+	Slots     int               `json:"slots"`     // The number of slots requested in the parallel environment
+	Resources map[string]string `json:"resources"` // Hard resource requests (-l)
+	Tasks     *TaskIDRange      `json:"tasks"`     // The task ids of an array job (-t), nil for a single job
+	Hold      bool              `json:"hold"`      // Submit the job with a user hold (-h)
+}
+
+// args returns the qsub arguments for the options
+func (o SubmitOptions) args() ([]string, error) {
+	var args []string
+	if o.Name != "" {
+		args = append(args, "-N", o.Name)
+	}
+	if o.Queue != "" {
+		args = append(args, "-q", o.Queue)
+	}
+	if o.PE != "" {
+		if o.Slots < 1 {
+			return nil, fmt.Errorf("qsub: invalid slot count %d for parallel environment %s", o.Slots, o.PE)
+		}
+		args = append(args, "-pe", o.PE, strconv.Itoa(o.Slots))
+	}
+	if len(o.Resources) > 0 {
+		args = append(args, "-l", resourceList(o.Resources))
+	}
+	if o.Tasks != nil {
+		if err := o.Tasks.validate(); err != nil {
+			return nil, fmt.Errorf("qsub: %s", err)
+		}
+		args = append(args, "-t", o.Tasks.String())
+	}
+	if o.Hold {
+		args = append(args, "-h")
+	}
+	return args, nil
+}
+
+// resourceList returns the resource requests as the argument of a -l option, eg: h_vmem=2G,mem_free=1G,
+// with the resources sorted by name
+func resourceList(resources map[string]string) string {
+	var names []string
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var requests []string
+	for _, name := range names {
+		requests = append(requests, name+"="+resources[name])
+	}
+	return strings.Join(requests, ",")
+}
+
+// VerifyResult is the outcome of verifying a job submission.
+type VerifyResult struct {
+	Suitable bool     `json:"suitable"` // Whether a suitable queue was found for the job
+	Reasons  []string `json:"reasons"`  // The messages qsub printed explaining the result
+}
+
+// verifySuitable is the message qsub -w v prints when the job could be scheduled
+const verifySuitable = "verification: found suitable queue(s)"
+
+// VerifySubmission checks whether the job script could be scheduled with the options without submitting it,
+// by running qsub -w v.
+func (c *Client) VerifySubmission(script string, opts SubmitOptions) (VerifyResult, error) {
+	args, err := opts.args()
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	args = append(args, "-w", "v", script)
+
+	out, err := c.run(context.Background(), "qsub", args...)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	b, err := ioutil.ReadAll(out)
+	cerr := out.Close()
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("qsub: could not read output: %s", err)
+	}
+
+	// qsub exits with status 1 whether or not a suitable queue was found, so only the messages tell
+	var res VerifyResult
+	unsuitable := false
+	for _, line := range append(strings.Split(string(b), "\n"), warnings(out)...) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Unable to run job:") {
+			unsuitable = true
+			line = strings.TrimSpace(strings.TrimPrefix(line, "Unable to run job:"))
+		}
+		if line == "" || line == "Exiting." {
+			continue
+		}
+		if line == verifySuitable {
+			res.Suitable = true
+		} else if strings.Contains(line, "no suitable queues") {
+			unsuitable = true
+		}
+		res.Reasons = append(res.Reasons, line)
+	}
+
+	if !res.Suitable && !unsuitable {
+		if cerr != nil {
+			return VerifyResult{}, cerr
+		}
+		return VerifyResult{}, fmt.Errorf("qsub: could not verify job: unexpected output %q", b)
+	}
+	return res, nil
+}
+
+// SubmitError is returned when qsub rejects a job submission
+type SubmitError struct {
+	Reason string // Why qsub rejected the job, as it printed to standard error
+	Err    error  // The error qsub exited with, a *QstatError
+}
+
+func (e *SubmitError) Error() string {
+	return "qsub: job rejected: " + e.Reason
+}
+
+// Unwrap returns the error qsub exited with
+func (e *SubmitError) Unwrap() error {
+	return e.Err
+}
+
+// submitted matches the line qsub prints for a submitted job, eg: Your job 123 ("render") has been submitted,
+// or for an array job: Your job-array 123.1-10:1 ("render") has been submitted
+var submitted = regexp.MustCompile(`^Your job(?:-array)? ([0-9]+)`)
+
+// Qsub submits the job script with the options and returns the job number it was given.
+// If qsub rejects the job the error is a *SubmitError giving the reason.
+func (c *Client) Qsub(script string, opts SubmitOptions) (jobNumber int, err error) {
+	args, err := opts.args()
+	if err != nil {
+		return 0, err
+	}
+	out, err := c.output(context.Background(), "qsub", append(args, script)...)
+	if err != nil {
+		var qerr *QstatError
+		if errors.As(err, &qerr) && qerr.Stderr != "" {
+			var reasons []string
+			for _, line := range strings.Split(qerr.Stderr, "\n") {
+				line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "Unable to run job:"))
+				if line != "" && line != "Exiting." {
+					reasons = append(reasons, line)
+				}
+			}
+			return 0, &SubmitError{strings.Join(reasons, "; "), err}
+		}
+		return 0, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := submitted.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			return strconv.Atoi(m[1])
+		}
+	}
+	return 0, fmt.Errorf("qsub: no job number in output %q", strings.TrimSpace(string(out)))
+}
+
+// Qsub submits a job script using DefaultClient
+func Qsub(script string, opts SubmitOptions) (jobNumber int, err error) {
+	return DefaultClient.Qsub(script, opts)
+}
@@ -0,0 +1,154 @@
+package qstat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSubmitOptionsArgs(t *testing.T) {
+	opts := SubmitOptions{
+		Name:      "sim",
+		Queue:     "all.q",
+		PE:        "smp",
+		Slots:     4,
+		Resources: map[string]string{"h_vmem": "2G", "h_rt": "1:00:00"},
+		Tasks:     &TaskIDRange{1, 10, 2},
+		Hold:      true,
+	}
+	args, err := opts.args()
+	if err != nil {
+		t.Fatalf("args failed: %s", err)
+	}
+	expected := []string{"-N", "sim", "-q", "all.q", "-pe", "smp", "4", "-l", "h_rt=1:00:00,h_vmem=2G", "-t", "1-10:2", "-h"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("got %q, expected %q", args, expected)
+	}
+
+	for _, opts := range []SubmitOptions{{PE: "smp"}, {Tasks: &TaskIDRange{5, 1, 1}}} {
+		if _, err := opts.args(); err == nil {
+			t.Errorf("%+v: expected error", opts)
+		}
+	}
+}
+
+func TestVerifySubmission(t *testing.T) {
+	r := &fakeRunner{output: []byte(verifySuitable + "\n")}
+	c := &Client{Runner: r}
+	res, err := c.VerifySubmission("job.sh", SubmitOptions{Queue: "all.q"})
+	if err != nil {
+		t.Fatalf("VerifySubmission failed: %s", err)
+	}
+	if !res.Suitable || !reflect.DeepEqual(res.Reasons, []string{verifySuitable}) {
+		t.Errorf("got %+v, expected suitable", res)
+	}
+	expected := []string{"-q", "all.q", "-w", "v", "job.sh"}
+	if cmd := r.commands[0]; cmd.name != "qsub" || !reflect.DeepEqual(cmd.args, expected) {
+		t.Errorf("ran %s %q, expected qsub %q", cmd.name, cmd.args, expected)
+	}
+
+	r = &fakeRunner{
+		stderr: "Unable to run job: error: no suitable queues.\nExiting.\n",
+		err:    errors.New("qsub: exit status 1"),
+	}
+	c = &Client{Runner: r}
+	res, err = c.VerifySubmission("job.sh", SubmitOptions{Resources: map[string]string{"gpu": "8"}})
+	if err != nil {
+		t.Fatalf("VerifySubmission failed: %s", err)
+	}
+	if res.Suitable || !reflect.DeepEqual(res.Reasons, []string{"error: no suitable queues."}) {
+		t.Errorf("got %+v, expected unsuitable with reason", res)
+	}
+
+	// qsub exits with status 1 and reports a suitable queue on standard error
+	r = &fakeRunner{
+		stderr: verifySuitable + "\n",
+		err:    &QstatError{ExitCode: 1, Stderr: verifySuitable},
+	}
+	c = &Client{Runner: r}
+	res, err = c.VerifySubmission("job.sh", SubmitOptions{})
+	if err != nil {
+		t.Fatalf("VerifySubmission failed: %s", err)
+	}
+	if !res.Suitable || !reflect.DeepEqual(res.Reasons, []string{verifySuitable}) {
+		t.Errorf("exit status 1: got %+v, expected suitable", res)
+	}
+
+	r = &fakeRunner{err: errors.New("qsub: exit status 2")}
+	c = &Client{Runner: r}
+	if _, err := c.VerifySubmission("job.sh", SubmitOptions{}); err == nil {
+		t.Errorf("expected error when qsub fails without a reason")
+	}
+
+	// An error unrelated to scheduling is not a reason the job is unsuitable
+	qerr := &QstatError{ExitCode: 1, Stderr: "error: commlib error: got select error (Connection refused)"}
+	r = &fakeRunner{stderr: qerr.Stderr + "\n", err: qerr}
+	c = &Client{Runner: r}
+	if res, err := c.VerifySubmission("job.sh", SubmitOptions{}); err != qerr {
+		t.Errorf("got %+v, %v, expected the qsub error", res, err)
+	}
+
+	r = &fakeRunner{output: []byte("something else\n")}
+	c = &Client{Runner: r}
+	if _, err := c.VerifySubmission("job.sh", SubmitOptions{}); err == nil {
+		t.Errorf("expected error for unrecognised output")
+	}
+}
+
+func TestQsub(t *testing.T) {
+	tests := []struct {
+		output   string
+		opts     SubmitOptions
+		expected int
+	}{
+		{"Your job 3064100 (\"render.sh\") has been submitted\n", SubmitOptions{}, 3064100},
+		{"Your job-array 3064101.1-10:1 (\"render\") has been submitted\n", SubmitOptions{Name: "render", Tasks: &TaskIDRange{1, 10, 1}}, 3064101},
+	}
+	for _, test := range tests {
+		r := &fakeRunner{output: []byte(test.output)}
+		c := &Client{Runner: r}
+		n, err := c.Qsub("render.sh", test.opts)
+		if err != nil {
+			t.Errorf("%q: Qsub failed: %s", test.output, err)
+			continue
+		}
+		if n != test.expected {
+			t.Errorf("%q: got job %d, expected %d", test.output, n, test.expected)
+		}
+		args, _ := test.opts.args()
+		if expected := append(args, "render.sh"); !reflect.DeepEqual(r.commands[0].args, expected) {
+			t.Errorf("ran qsub %q, expected qsub %q", r.commands[0].args, expected)
+		}
+	}
+
+	r := &fakeRunner{output: []byte("something unexpected\n")}
+	if _, err := (&Client{Runner: r}).Qsub("render.sh", SubmitOptions{}); err == nil {
+		t.Errorf("expected error for output without a job number")
+	}
+}
+
+func TestQsubRejected(t *testing.T) {
+	qerr := &QstatError{
+		ExitCode: 1,
+		Stderr:   "Unable to run job: unknown resource \"gpus\".\nExiting.",
+		Args:     []string{"qsub"},
+	}
+	r := &fakeRunner{err: qerr}
+	c := &Client{Runner: r}
+	_, err := c.Qsub("render.sh", SubmitOptions{Resources: map[string]string{"gpus": "1"}})
+	var serr *SubmitError
+	if !errors.As(err, &serr) {
+		t.Fatalf("got error %v, expected a *SubmitError", err)
+	}
+	if serr.Reason != `unknown resource "gpus".` {
+		t.Errorf("got reason %q", serr.Reason)
+	}
+	if !errors.Is(err, qerr) {
+		t.Errorf("SubmitError does not wrap the qsub exit status")
+	}
+
+	r.commands = nil
+	if _, err := c.Qsub("render.sh", SubmitOptions{PE: "mpi"}); err == nil || len(r.commands) != 0 {
+		t.Errorf("got error %v and ran %v for invalid options", err, r.commands)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"sort"
+)
+
+// SortByPriority sorts js in place by the priority reported by the scheduler, highest first.
+// Jobs with equal priority keep their relative order.
+func SortByPriority(js []QueueJob) {
+	sort.SliceStable(js, func(a, b int) bool {
+		return js[a].NormalizedPriority > js[b].NormalizedPriority
+	})
+}
+
+// SortBySubmissionTime sorts js in place by submission time, earliest first.
+// Jobs without a submission time that can be parsed, such as the running jobs in a job list, are
+// sorted last. Jobs with equal submission times keep their relative order.
+func SortBySubmissionTime(js []QueueJob) {
+	type submitted struct {
+		job QueueJob
+		ok  bool
+		t   int64
+	}
+	ss := make([]submitted, len(js))
+	for i, j := range js {
+		t, err := j.SubmissionTimeParsed()
+		ss[i] = submitted{j, err == nil && !t.IsZero(), t.UnixNano()}
+	}
+	sort.SliceStable(ss, func(a, b int) bool {
+		if ss[a].ok != ss[b].ok {
+			return ss[a].ok
+		}
+		return ss[a].ok && ss[a].t < ss[b].t
+	})
+	for i, s := range ss {
+		js[i] = s.job
+	}
+}
+
+// SortByJobNumber sorts js in place by job number, lowest first.
+// The tasks of an array job, which share a job number, keep their relative order.
+func SortByJobNumber(js []QueueJob) {
+	sort.SliceStable(js, func(a, b int) bool {
+		return js[a].JobNumber < js[b].JobNumber
+	})
+}
@@ -0,0 +1,33 @@
+package qstat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortJobs(t *testing.T) {
+	jobs := []QueueJob{
+		{JobNumber: 4, NormalizedPriority: 0.5, SubmissionTime: "2012-11-01T12:00:00"},
+		{JobNumber: 2, NormalizedPriority: 0.7, StartTime: "2012-11-01T09:00:00"},
+		{JobNumber: 3, NormalizedPriority: 0.5, SubmissionTime: "2012-11-01T11:00:00"},
+		{JobNumber: 1, NormalizedPriority: 0.9},
+		{JobNumber: 5, NormalizedPriority: 0.1, SubmissionTime: "2012-11-01T11:00:00"},
+	}
+	tests := []struct {
+		name     string
+		sort     func([]QueueJob)
+		expected []int
+	}{
+		{"priority", SortByPriority, []int{1, 2, 4, 3, 5}},
+		{"submission time", SortBySubmissionTime, []int{3, 5, 4, 2, 1}},
+		{"job number", SortByJobNumber, []int{1, 2, 3, 4, 5}},
+	}
+	for _, test := range tests {
+		js := make([]QueueJob, len(jobs))
+		copy(js, jobs)
+		test.sort(js)
+		if ns := jobNumbers(js); !reflect.DeepEqual(ns, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, ns, test.expected)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// SSHRunner is a Runner that runs commands on a remote host, such as a submit host, with ssh.
+// ssh must be able to log in without prompting, eg: using a key loaded in to an agent.
+type SSHRunner struct {
+	Host    string   // The host to log in to, optionally with a user, eg: bob@submit.example.com
+	SSH     string   // The ssh command, "ssh" if empty
+	Options []string // Extra arguments to ssh, eg: []string{"-p", "2222"}
+}
+
+// Run implements the Runner interface
+func (r SSHRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	ssh := r.SSH
+	if ssh == "" {
+		ssh = "ssh"
+	}
+	// The remote shell sees the command as a single string, so quote every word of it
+	words := []string{shellQuote(name)}
+	for _, a := range args {
+		words = append(words, shellQuote(a))
+	}
+	sshArgs := append(append([]string{}, r.Options...), "-o", "BatchMode=yes", "--", r.Host, strings.Join(words, " "))
+	return LocalRunner{}.Run(ctx, ssh, sshArgs...)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=+.,:/@%") == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,56 @@
+package qstat
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"qstat", "qstat"},
+		{"-xml", "-xml"},
+		{"/opt/sge/bin/lx-amd64/qstat", "/opt/sge/bin/lx-amd64/qstat"},
+		{"h_rt=1:00:00,h_vmem=2G", "h_rt=1:00:00,h_vmem=2G"},
+		{"*", "'*'"},
+		{"", "''"},
+		{"my job", "'my job'"},
+		{"bob's", `'bob'\''s'`},
+		{"$HOME;rm", "'$HOME;rm'"},
+	}
+	for _, test := range tests {
+		if q := shellQuote(test.s); q != test.expected {
+			t.Errorf("%q: got %s, expected %s", test.s, q, test.expected)
+		}
+	}
+}
+
+func TestSSHRunner(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	// A fake ssh which runs the remote command with the local shell, as sshd would
+	writeScript(t, dir, "ssh", `while [ "$1" != "--" ]; do shift; done; shift; host=$1; shift; echo "$host"; sh -c "$1"`)
+
+	r := SSHRunner{Host: "bob@submit", SSH: dir + "/ssh", Options: []string{"-p", "2222"}}
+	out, err := r.Run(context.Background(), "echo", "-u", "*", "it's")
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	b, err := ioutil.ReadAll(out)
+	if err != nil {
+		t.Fatalf("read failed: %s", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	if s := strings.TrimSpace(string(b)); s != "bob@submit\n-u * it's" {
+		t.Errorf("got output %q", s)
+	}
+}
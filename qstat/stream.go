@@ -0,0 +1,85 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/kisielk/gorge/util"
+)
+
+// StreamQueueJobs is like GetQueueInfo, but sends each job on the returned channel as soon as it is decoded
+// instead of collecting them all, which keeps memory use bounded for large clusters.
+// Running jobs are sent before pending jobs, in the order qstat lists them.
+// The job channel is closed when qstat's output is exhausted, after which the error channel
+// receives the error that ended the stream, if any, and is closed.
+// Cancelling ctx stops the stream and kills qstat.
+func (c *Client) StreamQueueJobs(ctx context.Context, u string) (<-chan QueueJob, <-chan error) {
+	jobs := make(chan QueueJob)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		err := c.streamQueueJobs(ctx, u, jobs)
+		close(jobs)
+		if err != nil {
+			errc <- err
+		}
+	}()
+	return jobs, errc
+}
+
+// StreamQueueJobs streams the jobs of user u using DefaultClient, see Client.StreamQueueJobs
+func StreamQueueJobs(ctx context.Context, u string) (<-chan QueueJob, <-chan error) {
+	return DefaultClient.StreamQueueJobs(ctx, u)
+}
+
+// streamQueueJobs runs qstat and sends every job_list element it outputs on jobs
+func (c *Client) streamQueueJobs(ctx context.Context, u string, jobs chan<- QueueJob) error {
+	if u == "" {
+		u = "*"
+	}
+	out, err := c.run(ctx, "qstat", "-xml", "-pri", "-ext", "-urg", "-u", u)
+	if err != nil {
+		return err
+	}
+	err = decodeQueueJobs(ctx, out, jobs)
+	if cerr := out.Close(); cerr != nil && ctx.Err() == nil {
+		return cerr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// decodeQueueJobs walks the qstat XML read from r, sending each job_list element on jobs as it is closed
+func decodeQueueJobs(ctx context.Context, r io.Reader, jobs chan<- QueueJob) error {
+	dec := xml.NewDecoder(util.NewValidUTF8Reader(r))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("qstat: could not decode output: %s", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "job_list" {
+			continue
+		}
+		var j QueueJob
+		if err := dec.DecodeElement(&j, &start); err != nil {
+			return fmt.Errorf("qstat: could not decode output: %s", err)
+		}
+		select {
+		case jobs <- j:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
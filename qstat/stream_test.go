@@ -0,0 +1,72 @@
+package qstat
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamQueueJobs(t *testing.T) {
+	var expected QueueInfo
+	if err := xml.Unmarshal([]byte(queueInfo), &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &fakeRunner{output: []byte(queueInfo)}
+	c := &Client{Runner: r}
+	jobs, errc := c.StreamQueueJobs(context.Background(), "")
+	var got []QueueJob
+	for j := range jobs {
+		got = append(got, j)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("StreamQueueJobs failed: %s", err)
+	}
+	if want := append(expected.QueuedJobs, expected.PendingJobs...); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, expected %+v", got, want)
+	}
+	args := []string{"-xml", "-pri", "-ext", "-urg", "-u", "*"}
+	if cmd := r.commands[0]; cmd.name != "qstat" || !reflect.DeepEqual(cmd.args, args) {
+		t.Errorf("ran %s %q, expected qstat %q", cmd.name, cmd.args, args)
+	}
+}
+
+func TestStreamQueueJobsErrors(t *testing.T) {
+	// Cut the output off in the middle of the pending job
+	truncated := queueInfo[:strings.Index(queueInfo, "<JB_name>Something")]
+	r := &fakeRunner{output: []byte(truncated)}
+	c := &Client{Runner: r}
+	jobs, errc := c.StreamQueueJobs(context.Background(), "bob")
+	n := 0
+	for range jobs {
+		n++
+	}
+	if err := <-errc; err == nil {
+		t.Errorf("expected error for truncated output")
+	}
+	if n != 1 {
+		t.Errorf("got %d jobs before the error, expected 1", n)
+	}
+
+	r = &fakeRunner{output: []byte(queueInfo), err: errors.New("qstat: exit status 1")}
+	c = &Client{Runner: r}
+	jobs, errc = c.StreamQueueJobs(context.Background(), "bob")
+	for range jobs {
+	}
+	if err := <-errc; err == nil || err.Error() != "qstat: exit status 1" {
+		t.Errorf("got error %v, expected qstat: exit status 1", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs, errc = (&Client{Runner: &fakeRunner{output: []byte(queueInfo)}}).StreamQueueJobs(ctx, "bob")
+	<-jobs
+	cancel()
+	for range jobs {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("got error %v, expected %v", err, context.Canceled)
+	}
+}
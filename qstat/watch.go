@@ -0,0 +1,187 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qstat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of change a QueueEvent describes
+type EventType string
+
+const (
+	JobAdded        EventType = "added"   // The job appeared in the queue
+	JobRemoved      EventType = "removed" // The job is no longer in the queue, it finished or was deleted
+	JobStateChanged EventType = "changed" // The state of the job changed
+)
+
+// QueueEvent describes a change in the state of a job between two QueueInfo snapshots.
+// Array jobs are tracked per entry in the job lists, so each range of tasks listed has its own events.
+type QueueEvent struct {
+	Time      time.Time `json:"time"`               // The time the change was observed
+	Type      EventType `json:"type"`               // The kind of change
+	JobNumber int       `json:"jobNumber"`          // The job number
+	Tasks     string    `json:"tasks,omitempty"`    // The tasks of the entry for array jobs
+	OldState  string    `json:"oldState,omitempty"` // The state before the change, empty for added jobs
+	NewState  string    `json:"newState,omitempty"` // The state after the change, empty for removed jobs
+	Job       QueueJob  `json:"job"`                // The job after the change, or before it was removed
+}
+
+// queueJobs indexes the queued and pending jobs of q
+func queueJobs(q *QueueInfo) (map[queueJobKey]QueueJob, []queueJobKey) {
+	jobs := make(map[queueJobKey]QueueJob)
+	var order []queueJobKey
+	if q == nil {
+		return jobs, order
+	}
+	for _, list := range [][]QueueJob{q.QueuedJobs, q.PendingJobs} {
+		for _, j := range list {
+			k := queueJobKey{j.JobNumber, j.Tasks}
+			if _, ok := jobs[k]; !ok {
+				order = append(order, k)
+			}
+			jobs[k] = j
+		}
+	}
+	return jobs, order
+}
+
+// DiffQueueInfo returns the events that transform the snapshot prev in to next.
+// Events for jobs in next are listed first, in the order they appear, followed by removed jobs.
+// The Time of the events is left unset.
+func DiffQueueInfo(prev, next *QueueInfo) []QueueEvent {
+	oldJobs, oldOrder := queueJobs(prev)
+	newJobs, newOrder := queueJobs(next)
+	var events []QueueEvent
+	for _, k := range newOrder {
+		j := newJobs[k]
+		o, ok := oldJobs[k]
+		switch {
+		case !ok:
+			events = append(events, QueueEvent{Type: JobAdded, JobNumber: j.JobNumber, Tasks: j.Tasks, NewState: j.State, Job: j})
+		case o.State != j.State:
+			events = append(events, QueueEvent{Type: JobStateChanged, JobNumber: j.JobNumber, Tasks: j.Tasks, OldState: o.State, NewState: j.State, Job: j})
+		}
+	}
+	for _, k := range oldOrder {
+		if _, ok := newJobs[k]; !ok {
+			o := oldJobs[k]
+			events = append(events, QueueEvent{Type: JobRemoved, JobNumber: o.JobNumber, Tasks: o.Tasks, OldState: o.State, Job: o})
+		}
+	}
+	return events
+}
+
+// EventLog appends QueueEvents to a file as JSON, one event per line.
+type EventLog struct {
+	Path    string // The path of the log file
+	MaxSize int64  // If greater than 0, the file is rotated to Path + ".1" before it grows beyond MaxSize bytes
+
+	mu sync.Mutex
+}
+
+// Append writes e to the end of the log
+func (l *EventLog) Append(e QueueEvent) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.MaxSize > 0 {
+		if fi, err := os.Stat(l.Path); err == nil && fi.Size() > 0 && fi.Size()+int64(len(line)) > l.MaxSize {
+			if err := os.Rename(l.Path, l.Path+".1"); err != nil {
+				return err
+			}
+		}
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Watcher polls the queue and reports changes in the state of jobs as QueueEvents.
+type Watcher struct {
+	Client   *Client       // The Client used to query the queue, DefaultClient if nil
+	User     string        // The user whose jobs are watched, as for GetQueueInfo
+	Interval time.Duration // The time between polls, which must be positive
+	Log      *EventLog     // If not nil, every event is also appended to the log
+	OnError  func(error)   // If not nil, called with errors querying the queue or writing the log
+
+	last *QueueInfo
+}
+
+func (w *Watcher) error(err error) {
+	if w.OnError != nil {
+		w.OnError(err)
+	}
+}
+
+// poll queries the queue and returns the events since the previous successful poll.
+// The first poll establishes the initial state and returns no events.
+func (w *Watcher) poll(ctx context.Context, now time.Time) []QueueEvent {
+	c := w.Client
+	if c == nil {
+		c = DefaultClient
+	}
+	q, err := c.queueInfo(ctx, w.User)
+	if err != nil {
+		w.error(err)
+		return nil
+	}
+	last := w.last
+	w.last = q
+	if last == nil {
+		return nil
+	}
+	events := DiffQueueInfo(last, q)
+	for i := range events {
+		events[i].Time = now
+		if w.Log != nil {
+			if err := w.Log.Append(events[i]); err != nil {
+				w.error(err)
+			}
+		}
+	}
+	return events
+}
+
+// Run polls the queue every Interval and sends the events observed to events until ctx is done.
+// Failed polls and log writes are reported to OnError and do not stop the watcher.
+// It returns an error without polling if Interval is not positive.
+func (w *Watcher) Run(ctx context.Context, events chan<- QueueEvent) error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("qstat: invalid watcher interval %s", w.Interval)
+	}
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+	for {
+		for _, e := range w.poll(ctx, time.Now()) {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package qstat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffQueueInfo(t *testing.T) {
+	prev := &QueueInfo{
+		QueuedJobs:  []QueueJob{{JobNumber: 1, State: "r"}, {JobNumber: 2, State: "r"}},
+		PendingJobs: []QueueJob{{JobNumber: 3, State: "qw"}, {JobNumber: 4, State: "qw", Tasks: "1-10"}},
+	}
+	next := &QueueInfo{
+		QueuedJobs:  []QueueJob{{JobNumber: 1, State: "r"}, {JobNumber: 3, State: "r"}, {JobNumber: 4, State: "r", Tasks: "1"}},
+		PendingJobs: []QueueJob{{JobNumber: 4, State: "qw", Tasks: "2-10"}, {JobNumber: 5, State: "hqw"}},
+	}
+
+	type change struct {
+		Type      EventType
+		JobNumber int
+		Tasks     string
+		OldState  string
+		NewState  string
+	}
+	var got []change
+	for _, e := range DiffQueueInfo(prev, next) {
+		got = append(got, change{e.Type, e.JobNumber, e.Tasks, e.OldState, e.NewState})
+	}
+	expected := []change{
+		{JobStateChanged, 3, "", "qw", "r"},
+		{JobAdded, 4, "1", "", "r"},
+		{JobAdded, 4, "2-10", "", "qw"},
+		{JobAdded, 5, "", "", "hqw"},
+		{JobRemoved, 2, "", "r", ""},
+		{JobRemoved, 4, "1-10", "qw", ""},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+	if events := DiffQueueInfo(next, next); len(events) != 0 {
+		t.Errorf("expected no events for an unchanged queue, got %v", events)
+	}
+}
+
+// readEvents reads back an event log file
+func readEvents(t *testing.T, path string) []QueueEvent {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open log: %s", err)
+	}
+	defer f.Close()
+	var events []QueueEvent
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e QueueEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("could not parse log line %q: %s", s.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestWatcherLog(t *testing.T) {
+	pending := strings.Replace(queueInfo, "<state>r</state>", "<state>t</state>", 1)
+	r := &fakeRunner{outputs: [][]byte{[]byte(pending), []byte(queueInfo), []byte(queueInfo)}}
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	w := &Watcher{Client: &Client{Runner: r}, User: "*", Log: &EventLog{Path: path}}
+
+	now := time.Date(2012, 11, 1, 13, 6, 41, 0, time.UTC)
+	if events := w.poll(context.Background(), now); len(events) != 0 {
+		t.Errorf("expected no events on first poll, got %v", events)
+	}
+	events := w.poll(context.Background(), now.Add(time.Minute))
+	if len(events) != 1 || events[0].Type != JobStateChanged || events[0].OldState != "t" || events[0].NewState != "r" {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	if events := w.poll(context.Background(), now.Add(2*time.Minute)); len(events) != 0 {
+		t.Errorf("expected no events for an unchanged queue, got %v", events)
+	}
+
+	logged := readEvents(t, path)
+	if len(logged) != 1 {
+		t.Fatalf("got %d logged events, expected 1", len(logged))
+	}
+	if !logged[0].Time.Equal(now.Add(time.Minute)) || logged[0].JobNumber != 3064076 || logged[0].Job.Owner != "bob" {
+		t.Errorf("unexpected logged event: %+v", logged[0])
+	}
+}
+
+func TestWatcherLogError(t *testing.T) {
+	r := &fakeRunner{outputs: [][]byte{[]byte(`<job_info></job_info>`), []byte(queueInfo)}}
+	var errs []error
+	w := &Watcher{
+		Client:  &Client{Runner: r},
+		Log:     &EventLog{Path: filepath.Join(t.TempDir(), "missing", "events.jsonl")},
+		OnError: func(err error) { errs = append(errs, err) },
+	}
+	w.poll(context.Background(), time.Now())
+	if events := w.poll(context.Background(), time.Now()); len(events) != 2 {
+		t.Errorf("got %d events, expected 2 despite log errors", len(events))
+	}
+	if len(errs) != 2 {
+		t.Errorf("got %d errors, expected 2", len(errs))
+	}
+}
+
+func TestWatcherInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		r := &fakeRunner{output: []byte(queueInfo)}
+		w := &Watcher{Client: &Client{Runner: r}, Interval: interval}
+		if err := w.Run(context.Background(), make(chan QueueEvent)); err == nil {
+			t.Errorf("%s: expected an error", interval)
+		}
+		if len(r.commands) != 0 {
+			t.Errorf("%s: polled the queue with an invalid interval", interval)
+		}
+	}
+}
+
+func TestEventLogRotate(t *testing.T) {
+	line, err := json.Marshal(QueueEvent{Type: JobAdded, JobNumber: 1})
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	// Room for two events per file
+	l := &EventLog{Path: path, MaxSize: int64(2*(len(line)+1) + 1)}
+	for i := 1; i <= 3; i++ {
+		if err := l.Append(QueueEvent{Type: JobAdded, JobNumber: i}); err != nil {
+			t.Fatalf("Append failed: %s", err)
+		}
+	}
+	if got := jobNumbersOf(readEvents(t, path+".1")); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("rotated log got %v, expected [1 2]", got)
+	}
+	if got := jobNumbersOf(readEvents(t, path)); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("current log got %v, expected [3]", got)
+	}
+}
+
+func jobNumbersOf(events []QueueEvent) []int {
+	var ns []int
+	for _, e := range events {
+		ns = append(ns, e.JobNumber)
+	}
+	return ns
+}
@@ -0,0 +1,33 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"fmt"
+	"math"
+)
+
+// byteUnits are the binary units FormatBytes uses, in increasing size
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// FormatBytes formats a number of bytes using binary units with one decimal place, eg: 1.5 GiB.
+// Values under 1 KiB are formatted as a whole number of bytes. A value is given in the next larger
+// unit once it would round to 1024, so 1023.99 MiB is formatted as 1.0 GiB rather than 1024.0 MiB.
+func FormatBytes(b float64) string {
+	sign := ""
+	if b < 0 {
+		sign, b = "-", -b
+	}
+	if math.Round(b) < 1024 {
+		return fmt.Sprintf("%s%.0f B", sign, b)
+	}
+	b /= 1024
+	unit := 0
+	for unit < len(byteUnits)-1 && math.Round(b*10)/10 >= 1024 {
+		b /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%s%.1f %s", sign, b, byteUnits[unit])
+}
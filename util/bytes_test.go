@@ -0,0 +1,33 @@
+package util
+
+import "testing"
+
+func TestFormatBytes(t *testing.T) {
+	const MiB = 1024 * 1024
+	tests := []struct {
+		b        float64
+		expected string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1023, "1023 B"},
+		{1023.4, "1023 B"},
+		{1023.5, "1.0 KiB"},
+		{1023.7, "1.0 KiB"},
+		{1023.95, "1.0 KiB"},
+		{-1023.7, "-1.0 KiB"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1023 * MiB, "1023.0 MiB"},
+		{1023.94 * MiB, "1023.9 MiB"},
+		{1023.96 * MiB, "1.0 GiB"},
+		{1024 * MiB, "1.0 GiB"},
+		{2.5 * 1024 * MiB, "2.5 GiB"},
+		{-1536, "-1.5 KiB"},
+	}
+	for _, test := range tests {
+		if s := FormatBytes(test.b); s != test.expected {
+			t.Errorf("%v: got %q, expected %q", test.b, s, test.expected)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfiniteDuration is the duration ParseSGEDuration returns for INFINITY, the time value of an unlimited h_rt or s_rt.
+// It is the longest time.Duration.
+const InfiniteDuration = time.Duration(math.MaxInt64)
+
+// maxDurationSeconds is the largest number of seconds a time.Duration can hold
+const maxDurationSeconds = math.MaxInt64 / int64(time.Second)
+
+// ParseSGEDuration parses a GridEngine time value such as the ones used for h_rt and s_rt.
+// The value is either a number of seconds, eg: 3600, or in the form [[H:]M:]S, eg: 36:00:00 or 1:30:00.
+// Empty fields in the colon form are treated as zero, so ::30 is 30 seconds.
+// INFINITY, in any case, is parsed as InfiniteDuration. Values too long for a time.Duration are invalid.
+func ParseSGEDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "INFINITY") {
+		return InfiniteDuration, nil
+	}
+	fields := strings.Split(s, ":")
+	if len(fields) > 3 || (len(fields) == 1 && fields[0] == "") {
+		return 0, fmt.Errorf("util: invalid time value %q", s)
+	}
+
+	var seconds int64
+	for _, f := range fields {
+		var n int64
+		if f != "" {
+			var err error
+			n, err = strconv.ParseInt(f, 10, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("util: invalid time value %q", s)
+			}
+		}
+		if n > maxDurationSeconds || seconds > (maxDurationSeconds-n)/60 {
+			return 0, fmt.Errorf("util: invalid time value %q", s)
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// FormatSGEDuration formats d as a GridEngine time value in the form H:MM:SS, truncating it to whole seconds.
+// Negative durations are formatted as 0:00:00, and InfiniteDuration as INFINITY.
+func FormatSGEDuration(d time.Duration) string {
+	if d == InfiniteDuration {
+		return "INFINITY"
+	}
+	if d < 0 {
+		d = 0
+	}
+	seconds := int64(d / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSGEDuration(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected time.Duration
+	}{
+		{"3600", time.Hour},
+		{"0", 0},
+		{"36:00:00", 36 * time.Hour},
+		{"1:30:00", 90 * time.Minute},
+		{"0:0:0", 0},
+		{"90:30", 90*time.Minute + 30*time.Second},
+		{"::30", 30 * time.Second},
+		{"1::", time.Hour},
+		{" 2:00:00\n", 2 * time.Hour},
+		{"9223372036", 9223372036 * time.Second},
+		{"INFINITY", InfiniteDuration},
+		{"infinity", InfiniteDuration},
+	}
+	for _, test := range tests {
+		d, err := ParseSGEDuration(test.s)
+		if err != nil {
+			t.Errorf("%q: ParseSGEDuration failed: %s", test.s, err)
+			continue
+		}
+		if d != test.expected {
+			t.Errorf("%q: got %s, expected %s", test.s, d, test.expected)
+		}
+	}
+
+	for _, s := range []string{"", "abc", "1:2:3:4", "-5", "1:-1:00", "1.5", "INFINITY:00",
+		"9999999999999", "9223372037", "2562048:00:00", "99999999999999999999"} {
+		if d, err := ParseSGEDuration(s); err == nil {
+			t.Errorf("%q: expected error, got %s", s, d)
+		}
+	}
+}
+
+func TestFormatSGEDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "0:00:00"},
+		{36 * time.Hour, "36:00:00"},
+		{90*time.Minute + 5*time.Second, "1:30:05"},
+		{1500 * time.Millisecond, "0:00:01"},
+		{-time.Second, "0:00:00"},
+		{InfiniteDuration, "INFINITY"},
+	}
+	for _, test := range tests {
+		if s := FormatSGEDuration(test.d); s != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.d, s, test.expected)
+		}
+		if test.d < 0 {
+			continue
+		}
+		expected := test.d.Truncate(time.Second)
+		if test.d == InfiniteDuration {
+			expected = InfiniteDuration
+		}
+		if d, err := ParseSGEDuration(FormatSGEDuration(test.d)); err != nil || d != expected {
+			t.Errorf("%s: round trip got %s, %v", test.d, d, err)
+		}
+	}
+}